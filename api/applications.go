@@ -1,25 +1,41 @@
 package api
 
 import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	"github.com/SimonRichardson/juju-api-example/client"
 	"github.com/SimonRichardson/juju-api-example/common"
 	"github.com/juju/charm/v8"
 	"github.com/juju/clock"
 	"github.com/juju/collections/set"
 	"github.com/juju/errors"
+	"github.com/juju/juju/api"
 	"github.com/juju/juju/api/application"
-	"github.com/juju/juju/api/base"
 	apicharms "github.com/juju/juju/api/charms"
 	commoncharm "github.com/juju/juju/api/common/charm"
 	"github.com/juju/juju/api/modelconfig"
+	resourcesclient "github.com/juju/juju/api/resources/client"
 	"github.com/juju/juju/apiserver/params"
 	"github.com/juju/juju/cmd/juju/application/utils"
+	corecharm "github.com/juju/juju/core/charm"
 	"github.com/juju/juju/core/constraints"
+	"github.com/juju/juju/core/devices"
+	"github.com/juju/juju/core/instance"
 	"github.com/juju/juju/core/series"
 	"github.com/juju/juju/environs/config"
+	"github.com/juju/juju/storage"
 	"github.com/juju/names/v4"
 )
 
+// trustConfigOptionName is the application config option that grants an
+// application access to the model's cloud credential.
+const trustConfigOptionName = "trust"
+
 type ApplicationsAPI struct {
 	client *client.Client
 }
@@ -40,103 +56,254 @@ type DeployArgs struct {
 	Constraints     constraints.Value
 	ImageStream     string
 	Force           bool
+
+	// Config holds the initial charm config, keyed by option name.
+	Config map[string]interface{}
+	// Trust grants the application access to the model's cloud credential.
+	Trust bool
+	// EndpointBindings maps charm endpoints to spaces. The empty endpoint
+	// sets the application's default space.
+	EndpointBindings map[string]string
+	// Storage holds the storage directives, keyed by charm storage name.
+	Storage map[string]storage.Constraints
+	// Devices holds the device constraints, keyed by charm device name.
+	Devices map[string]devices.Constraints
+	// Placement holds the placement directives for the new units.
+	Placement []*instance.Placement
+	// AttachStorage holds the IDs of existing storage to attach to the
+	// new unit. It requires NumUnits to be 1.
+	AttachStorage []string
+	// Resources maps charm resource names to either a store revision or
+	// the path of a file to upload.
+	Resources map[string]string
 }
 
+// Deploy deploys the charm to the model. The charm is either a store charm
+// name or URL, or the path of a local charm, which must start with "." or
+// "local:", or be absolute.
 func (s *ApplicationsAPI) Deploy(modelName string, charmName string, args DeployArgs) error {
-	if args.ApplicationName == "" {
-		args.ApplicationName = charmName
-	}
-	if err := names.ValidateApplicationName(args.ApplicationName); err != nil {
+	return s.DeployContext(context.Background(), modelName, charmName, args)
+}
+
+// DeployContext is like Deploy, but abandons any in-flight API calls once
+// the given context is done.
+func (s *ApplicationsAPI) DeployContext(ctx context.Context, modelName string, charmName string, args DeployArgs) error {
+	localCharm, err := prepareDeployArgs(charmName, &args)
+	if err != nil {
 		return errors.Trace(err)
 	}
 
-	apiRoot, err := s.client.NewModelAPIRoot(modelName)
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	defer func() { _ = apiRoot.Close() }()
 
-	charmAPIClient := apicharms.NewClient(apiRoot)
-	defaultCharmSchema := charm.CharmHub
-	if charmAPIClient.BestAPIVersion() < 3 {
-		defaultCharmSchema = charm.CharmStore
+	deployCtx, err := newDeployContext(apiRoot)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if localCharm != nil {
+		return s.deployLocalCharm(deployCtx, localCharm, args)
 	}
 
-	applicationsAPIClient := application.NewClient(apiRoot)
-
-	modelAPIClient := modelconfig.NewClient(apiRoot)
-	attrs, err := modelAPIClient.ModelGet()
+	charmURL, origin, err := storeCharmOrigin(deployCtx, charmName, &args)
 	if err != nil {
-		return errors.Wrap(err, errors.New("cannot fetch model settings"))
+		return errors.Trace(err)
 	}
+	return s.prepareAndDeploy(deployCtx, charmURL, origin, args)
+}
 
-	modelConfig, err := config.New(config.NoDefaults, attrs)
+// prepareDeployArgs reads the charm if it's on disk and defaults the
+// application name, returning the local charm, if any.
+func prepareDeployArgs(charmName string, args *DeployArgs) (charm.Charm, error) {
+	localCharm, err := readLocalCharm(charmName)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
+	}
+	if args.ApplicationName == "" {
+		args.ApplicationName = charmName
+		if localCharm != nil {
+			args.ApplicationName = localCharm.Meta().Name
+		}
 	}
+	if err := names.ValidateApplicationName(args.ApplicationName); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return localCharm, nil
+}
 
-	userRequestedURL, err := resolveCharmURL(charmName, defaultCharmSchema)
+// storeCharmOrigin checks the requested revision and channel for a store
+// charm, returning the URL and origin to resolve it with. The workload
+// series are filled in if they haven't been supplied.
+func storeCharmOrigin(ctx deployContext, charmName string, args *DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	userRequestedURL, err := resolveCharmURL(charmName, defaultCharmSchema(ctx))
 	if err != nil {
-		return errors.Trace(err)
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
 	// To deploy by revision, the revision number must be in the origin for a
 	// charmhub charm and in the url for a charmstore charm.
 	if charm.CharmHub.Matches(userRequestedURL.Schema) {
 		if userRequestedURL.Revision != -1 {
-			return errors.Errorf("cannot specify revision in a charm or bundle name. Please use --revision.")
+			return nil, commoncharm.Origin{}, errors.Errorf("cannot specify revision in a charm or bundle name. Please use --revision.")
 		}
 		if args.Revision != -1 && args.Channel.Empty() {
-			return errors.Errorf("specifying a revision requires a channel for future upgrades. Please use --channel")
+			return nil, commoncharm.Origin{}, errors.Errorf("specifying a revision requires a channel for future upgrades. Please use --channel")
 		}
 	} else if charm.CharmStore.Matches(userRequestedURL.Schema) {
 		if userRequestedURL.Revision != -1 && args.Revision != -1 && userRequestedURL.Revision != args.Revision {
-			return errors.Errorf("two different revisions to deploy: specified %d and %d, please choose one.", userRequestedURL.Revision, args.Revision)
-		}
-		if userRequestedURL.Revision == -1 && args.Revision != -1 {
-			userRequestedURL = userRequestedURL.WithRevision(args.Revision)
+			return nil, commoncharm.Origin{}, errors.Errorf("two different revisions to deploy: specified %d and %d, please choose one.", userRequestedURL.Revision, args.Revision)
 		}
 	}
 
-	modelConstraints, err := GetModelConstraints(apiRoot)
+	userRequestedURL, origin, err := storeOrigin(ctx, userRequestedURL, args.Revision, args.Channel, args.Constraints, args.Series)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
 
-	platform, err := utils.DeducePlatform(args.Constraints, args.Series, modelConstraints)
-	if err != nil {
-		return errors.Trace(err)
+	if err := ensureWorkloadSeries(args, userRequestedURL.Series, ctx.ModelConfig); err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+
+	return userRequestedURL, origin, nil
+}
+
+// defaultCharmSchema returns the schema charm names without one are
+// resolved with: CharmHub, or the charm store on controllers too old to
+// support it.
+func defaultCharmSchema(ctx deployContext) charm.Schema {
+	if ctx.CharmAPIClient.BestAPIVersion() < 3 {
+		return charm.CharmStore
 	}
+	return charm.CharmHub
+}
 
-	urlForOrigin := userRequestedURL
-	if args.Revision != -1 {
-		urlForOrigin = urlForOrigin.WithRevision(args.Revision)
+// storeOrigin deduces the origin to resolve a store charm with, for the
+// platform the constraints and series select. The revision, which is -1
+// for the latest, must be in the origin for a charmhub charm and in the URL
+// for a charmstore charm, so the URL is returned with it for the latter.
+func storeOrigin(
+	ctx deployContext,
+	charmURL *charm.URL, revision int, channel charm.Channel,
+	cons constraints.Value, series string,
+) (*charm.URL, commoncharm.Origin, error) {
+	urlForOrigin := charmURL
+	if revision != -1 {
+		urlForOrigin = charmURL.WithRevision(revision)
+		if charm.CharmStore.Matches(charmURL.Schema) {
+			charmURL = urlForOrigin
+		}
 	}
-	origin, err := utils.DeduceOrigin(urlForOrigin, args.Channel, platform)
+
+	modelConstraints, err := modelConstraints(ctx.APIRoot)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	platform, err := utils.DeducePlatform(cons, series, modelConstraints)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	origin, err := utils.DeduceOrigin(urlForOrigin, channel, platform)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
+	return charmURL, origin, nil
+}
 
-	if args.WorkloadSeries == nil {
-		imageStream := args.ImageStream
-		if imageStream == "" {
-			imageStream = modelConfig.ImageStream()
-		}
+// resolveCharm resolves a store charm, returning the store's choice. Unless
+// keepRevision is set, the origin's revision is dropped so that the latest
+// revision in its channel is resolved.
+func resolveCharm(ctx deployContext, charmURL *charm.URL, origin commoncharm.Origin, switchCharm, keepRevision bool) (apicharms.ResolvedCharm, error) {
+	if !keepRevision {
+		rev := -1
+		origin.Revision = &rev
+	}
+	resolved, err := ctx.CharmAPIClient.ResolveCharms([]apicharms.CharmToResolve{{
+		URL:         charmURL,
+		Origin:      origin,
+		SwitchCharm: switchCharm,
+	}})
+	if charm.IsUnsupportedSeriesError(err) {
+		return apicharms.ResolvedCharm{}, errors.Errorf("%v. Use --force to deploy the charm anyway.", err)
+	} else if err != nil {
+		return apicharms.ResolvedCharm{}, errors.Trace(err)
+	}
+	if len(resolved) != 1 {
+		return apicharms.ResolvedCharm{}, errors.Errorf("expected only one resolution, received %d", len(resolved))
+	}
+	if err := resolved[0].Error; err != nil {
+		return apicharms.ResolvedCharm{}, errors.Trace(err)
+	}
+	return resolved[0], nil
+}
 
-		workloadSeries, err := series.WorkloadSeries(clock.WallClock.Now(), userRequestedURL.Series, imageStream)
-		if err != nil {
-			return errors.Trace(err)
-		}
-		args.WorkloadSeries = workloadSeries
+// resolvedCharmOrigin returns the URL and origin to add a resolved charm
+// with, for the given series. A charmhub charm's URL is completed from its
+// origin, while a charmstore charm's revision is copied from its URL to its
+// origin.
+func resolvedCharmOrigin(selected apicharms.ResolvedCharm, series string) (*charm.URL, commoncharm.Origin) {
+	origin := selected.Origin.WithSeries(series)
+	charmURL := selected.URL
+	if charm.CharmHub.Matches(charmURL.Schema) {
+		charmURL = charmURL.WithRevision(*origin.Revision).WithArchitecture(origin.Architecture).WithSeries(series)
+	} else if charm.CharmStore.Matches(charmURL.Schema) {
+		origin.Revision = &charmURL.Revision
+	}
+	return charmURL, origin
+}
+
+// newDeployContext gathers the clients and model config needed to deploy
+// charms to the model.
+func newDeployContext(apiRoot api.Connection) (deployContext, error) {
+	modelAPIClient := modelconfig.NewClient(apiRoot)
+	attrs, err := modelAPIClient.ModelGet()
+	if err != nil {
+		return deployContext{}, errors.Wrap(err, errors.New("cannot fetch model settings"))
 	}
 
-	return s.prepareAndDeploy(deployContext{
-		CharmAPIClient:       charmAPIClient,
-		ApplicationAPIClient: applicationsAPIClient,
+	modelConfig, err := config.New(config.NoDefaults, attrs)
+	if err != nil {
+		return deployContext{}, errors.Trace(err)
+	}
+
+	return deployContext{
+		APIRoot:              apiRoot,
+		LocalCharmClient:     apiRoot.Client(),
+		CharmAPIClient:       apicharms.NewClient(apiRoot),
+		ApplicationAPIClient: application.NewClient(apiRoot),
 		ModelAPIClient:       modelAPIClient,
 		ModelConfig:          modelConfig,
-	}, userRequestedURL, origin, args)
+	}, nil
+}
+
+// ensureWorkloadSeries fills in the series Juju can deploy workloads to, if
+// they haven't been supplied, using the requested image stream or the
+// model's.
+func ensureWorkloadSeries(args *DeployArgs, requestedSeries string, modelConfig *config.Config) error {
+	if args.WorkloadSeries != nil {
+		return nil
+	}
+	imageStream := args.ImageStream
+	if imageStream == "" {
+		imageStream = modelConfig.ImageStream()
+	}
+
+	workloadSeries, err := series.WorkloadSeries(clock.WallClock.Now(), requestedSeries, imageStream)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	args.WorkloadSeries = workloadSeries
+	return nil
+}
+
+// localCharmClient uploads charms read from disk to the controller.
+type localCharmClient interface {
+	AddLocalCharm(curl *charm.URL, ch charm.Charm, force bool) (*charm.URL, error)
 }
 
 type deployContext struct {
+	APIRoot              api.Connection
+	LocalCharmClient     localCharmClient
 	CharmAPIClient       *apicharms.Client
 	ApplicationAPIClient *application.Client
 	ModelAPIClient       *modelconfig.Client
@@ -146,22 +313,59 @@ type deployContext struct {
 // PrepareAndDeploy finishes preparing to deploy a charm store charm,
 // then deploys it.
 func (s *ApplicationsAPI) prepareAndDeploy(ctx deployContext, charmURL *charm.URL, origin commoncharm.Origin, requestedArgs DeployArgs) error {
-	// Charm or bundle has been supplied as a URL so we resolve and
-	// deploy using the store but pass in the origin command line
-	// argument so users can target a specific origin.
-	rev := -1
-	origin.Revision = &rev
-	resolved, err := ctx.CharmAPIClient.ResolveCharms([]apicharms.CharmToResolve{{URL: charmURL, Origin: origin}})
-	if charm.IsUnsupportedSeriesError(err) {
-		return errors.Errorf("%v. Use --force to deploy the charm anyway.", err)
-	} else if err != nil {
+	charmURL, origin, err := resolveStoreCharm(ctx, charmURL, origin, requestedArgs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// The charm's metadata is only available once it's in the model, so
+	// the arguments can only be checked up front if it has already been
+	// added, say for another application. Otherwise they're checked once
+	// it's added, before deploying.
+	charmInfo, err := ctx.CharmAPIClient.CharmInfo(charmURL.String())
+	if err == nil {
+		if _, err := validateDeployArgs(charmInfo.Meta, charmInfo.Config, requestedArgs); err != nil {
+			return errors.Trace(err)
+		}
+	} else if !params.IsCodeNotFound(err) {
+		return errors.Trace(err)
+	}
+	charmURL, origin, err = addResolvedCharm(ctx, charmURL, origin, requestedArgs)
+	if err != nil {
 		return errors.Trace(err)
 	}
+	return s.deployCharm(ctx, charmURL, origin, requestedArgs)
+}
 
-	if len(resolved) != 1 {
-		return errors.Errorf("expected only one resolution, received %d", len(resolved))
+// addStoreCharm resolves a store charm, selects its series and adds it to
+// the model, returning the resolved URL and origin.
+func addStoreCharm(ctx deployContext, charmURL *charm.URL, origin commoncharm.Origin, requestedArgs DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	charmURL, origin, err := resolveStoreCharm(ctx, charmURL, origin, requestedArgs)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	return addResolvedCharm(ctx, charmURL, origin, requestedArgs)
+}
+
+// addResolvedCharm adds a store charm that has already been resolved to the
+// model, returning the origin the controller recorded for it.
+func addResolvedCharm(ctx deployContext, charmURL *charm.URL, origin commoncharm.Origin, requestedArgs DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	resultOrigin, err := ctx.CharmAPIClient.AddCharm(charmURL, origin, requestedArgs.Force)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	return charmURL, resultOrigin, nil
+}
+
+// resolveStoreCharm resolves a store charm and selects its series, without
+// adding it to the model.
+func resolveStoreCharm(ctx deployContext, charmURL *charm.URL, origin commoncharm.Origin, requestedArgs DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	// Charm or bundle has been supplied as a URL so we resolve and
+	// deploy using the store but pass in the origin command line
+	// argument so users can target a specific origin.
+	selected, err := resolveCharm(ctx, charmURL, origin, false, false)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
-	selected := resolved[0]
 
 	selector := common.SeriesSelector{
 		CharmURLSeries:      charmURL.Series,
@@ -173,21 +377,119 @@ func (s *ApplicationsAPI) prepareAndDeploy(ctx deployContext, charmURL *charm.UR
 
 	series, err := selector.CharmSeries()
 	if err != nil {
-		return errors.Trace(err)
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
 	if err := validateCharmSeriesWithName(series, charmURL.Name, requestedArgs.WorkloadSeries); err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+
+	charmURL, origin = resolvedCharmOrigin(selected, series)
+	return charmURL, origin, nil
+}
+
+// deployLocalCharm uploads a charm read from disk and deploys it. The series
+// is selected from the charm's metadata in the same way as for store charms.
+func (s *ApplicationsAPI) deployLocalCharm(ctx deployContext, ch charm.Charm, requestedArgs DeployArgs) error {
+	if err := ensureWorkloadSeries(&requestedArgs, requestedArgs.Series, ctx.ModelConfig); err != nil {
+		return errors.Trace(err)
+	}
+	// Check the arguments against the charm on disk before uploading it.
+	if _, err := validateDeployArgs(ch.Meta(), ch.Config(), requestedArgs); err != nil {
+		return errors.Trace(err)
+	}
+	charmURL, origin, err := addLocalCharm(ctx, ch, requestedArgs)
+	if err != nil {
 		return errors.Trace(err)
 	}
+	return s.deployCharm(ctx, charmURL, origin, requestedArgs)
+}
 
-	origin = selected.Origin.WithSeries(series)
-	if charm.CharmHub.Matches(charmURL.Schema) {
-		charmURL = selected.URL.WithRevision(*origin.Revision).WithArchitecture(origin.Architecture).WithSeries(series)
-	} else if charm.CharmStore.Matches(charmURL.Schema) {
-		charmURL = selected.URL
-		origin.Revision = &charmURL.Revision
+// addLocalCharm selects the series for a charm read from disk and uploads
+// it, returning the local URL and origin.
+func addLocalCharm(ctx deployContext, ch charm.Charm, requestedArgs DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	series, err := localCharmSeries(ctx, ch, requestedArgs)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	charmURL, err := ctx.LocalCharmClient.AddLocalCharm(localCharmURL(ch, series), ch, requestedArgs.Force)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	origin, err := localCharmOrigin(ctx, charmURL, requestedArgs.Constraints)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
 	}
+	return charmURL, origin, nil
+}
 
-	resultOrigin, err := ctx.CharmAPIClient.AddCharm(charmURL, origin, requestedArgs.Force)
+// localCharmSeries selects the series to deploy a charm read from disk to.
+func localCharmSeries(ctx deployContext, ch charm.Charm, requestedArgs DeployArgs) (string, error) {
+	name := ch.Meta().Name
+	supportedSeries, err := corecharm.ComputedSeries(ch)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	selector := common.SeriesSelector{
+		SeriesFlag:          requestedArgs.Series,
+		SupportedSeries:     supportedSeries,
+		SupportedJujuSeries: requestedArgs.WorkloadSeries,
+		Conf:                ctx.ModelConfig,
+		Force:               requestedArgs.Force,
+	}
+	series, err := selector.CharmSeries()
+	if err := charmValidationError(name, errors.Trace(err)); err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := validateCharmSeriesWithName(series, name, requestedArgs.WorkloadSeries); err != nil {
+		return "", errors.Trace(err)
+	}
+	return series, nil
+}
+
+// localCharmURL returns the URL a charm read from disk is uploaded as. The
+// controller may bump the revision if the URL is already in use.
+func localCharmURL(ch charm.Charm, series string) *charm.URL {
+	return &charm.URL{
+		Schema:   charm.Local.String(),
+		Name:     ch.Meta().Name,
+		Series:   series,
+		Revision: ch.Revision(),
+	}
+}
+
+// localCharmOrigin returns the origin of a local charm, deducing its
+// platform from the series and constraints.
+func localCharmOrigin(ctx deployContext, charmURL *charm.URL, cons constraints.Value) (commoncharm.Origin, error) {
+	modelConstraints, err := modelConstraints(ctx.APIRoot)
+	if err != nil {
+		return commoncharm.Origin{}, errors.Trace(err)
+	}
+	platform, err := utils.DeducePlatform(cons, charmURL.Series, modelConstraints)
+	if err != nil {
+		return commoncharm.Origin{}, errors.Trace(err)
+	}
+	origin, err := utils.DeduceOrigin(charmURL, charm.Channel{}, platform)
+	if err != nil {
+		return commoncharm.Origin{}, errors.Trace(err)
+	}
+	return origin, nil
+}
+
+// deployCharm deploys a charm that has already been added to the model.
+func (s *ApplicationsAPI) deployCharm(ctx deployContext, charmURL *charm.URL, resultOrigin commoncharm.Origin, requestedArgs DeployArgs) error {
+	charmInfo, err := ctx.CharmAPIClient.CharmInfo(charmURL.String())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	appConfig, err := validateDeployArgs(charmInfo.Meta, charmInfo.Config, requestedArgs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	resourceIDs, err := deployResources(ctx.APIRoot, requestedArgs.ApplicationName, resourcesclient.CharmID{
+		URL:    charmURL,
+		Origin: resultOrigin,
+	}, requestedArgs.Resources, charmInfo.Meta.Resources)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -197,14 +499,111 @@ func (s *ApplicationsAPI) prepareAndDeploy(ctx deployContext, charmURL *charm.UR
 			URL:    charmURL,
 			Origin: resultOrigin,
 		},
-		ApplicationName: requestedArgs.ApplicationName,
-		Series:          resultOrigin.Series,
-		NumUnits:        requestedArgs.NumUnits,
-		Cons:            requestedArgs.Constraints,
+		ApplicationName:  requestedArgs.ApplicationName,
+		Series:           resultOrigin.Series,
+		NumUnits:         requestedArgs.NumUnits,
+		Config:           appConfig,
+		Cons:             requestedArgs.Constraints,
+		Placement:        requestedArgs.Placement,
+		Storage:          requestedArgs.Storage,
+		Devices:          requestedArgs.Devices,
+		AttachStorage:    requestedArgs.AttachStorage,
+		EndpointBindings: requestedArgs.EndpointBindings,
+		Resources:        resourceIDs,
 	}
 	return ctx.ApplicationAPIClient.Deploy(deployArgs)
 }
 
+// validateDeployArgs checks the requested config, bindings, storage, devices
+// and resources against the charm's metadata and config schema, returning
+// the application config to deploy with.
+func validateDeployArgs(meta *charm.Meta, schema *charm.Config, args DeployArgs) (map[string]string, error) {
+	if meta == nil {
+		return nil, errors.NotValidf("charm without metadata")
+	}
+
+	appConfig := make(map[string]string)
+	if len(args.Config) > 0 {
+		if schema == nil {
+			schema = charm.NewConfig()
+		}
+		settings, err := schema.ValidateSettings(charm.Settings(args.Config))
+		if err != nil {
+			return nil, errors.NewNotValid(err, "charm config")
+		}
+		for name, value := range settings {
+			if value != nil {
+				appConfig[name] = formatConfigValue(value)
+			}
+		}
+	}
+	if args.Trust {
+		appConfig[trustConfigOptionName] = strconv.FormatBool(args.Trust)
+	}
+
+	endpoints := meta.CombinedRelations()
+	for endpoint := range args.EndpointBindings {
+		if endpoint == "" {
+			continue
+		}
+		_, isRelation := endpoints[endpoint]
+		_, isExtra := meta.ExtraBindings[endpoint]
+		if !isRelation && !isExtra {
+			return nil, errors.NotValidf("binding for endpoint %q not defined by charm %q", endpoint, meta.Name)
+		}
+	}
+	for name := range args.Storage {
+		if _, ok := meta.Storage[name]; !ok {
+			return nil, errors.NotValidf("storage %q not defined by charm %q", name, meta.Name)
+		}
+	}
+	for name := range args.Devices {
+		if _, ok := meta.Devices[name]; !ok {
+			return nil, errors.NotValidf("device %q not defined by charm %q", name, meta.Name)
+		}
+	}
+	for name := range args.Resources {
+		if _, ok := meta.Resources[name]; !ok {
+			return nil, errors.NotValidf("resource %q not defined by charm %q", name, meta.Name)
+		}
+	}
+	if len(args.AttachStorage) > 0 && args.NumUnits != 1 {
+		return nil, errors.NotValidf("attaching storage to %d units", args.NumUnits)
+	}
+	return appConfig, nil
+}
+
+// readLocalCharm reads the charm directory or archive at the given path,
+// which may carry a "local:" prefix. If the name isn't a local charm path,
+// nil is returned so it can be resolved as a store charm instead.
+func readLocalCharm(path string) (charm.Charm, error) {
+	if !isLocalCharmPath(path) {
+		return nil, nil
+	}
+	path = strings.TrimPrefix(path, charm.Local.Prefix(""))
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, errors.NotFoundf("charm at %q", path)
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ch, err := charm.ReadCharm(path)
+	if errors.Cause(err) == zip.ErrFormat {
+		return nil, errors.NotValidf("charm at %q", path)
+	} else if err != nil {
+		return nil, errors.Annotatef(err, "reading charm at %q", path)
+	}
+	return ch, nil
+}
+
+// isLocalCharmPath reports whether the name refers to a charm on disk,
+// rather than in a store. Only names that are explicitly paths are: those
+// starting with ".", absolute paths, and any name with a "local:" prefix,
+// which is read relative to the working directory.
+func isLocalCharmPath(name string) bool {
+	return strings.HasPrefix(name, ".") || filepath.IsAbs(name) || strings.HasPrefix(name, charm.Local.Prefix(""))
+}
+
 func resolveCharmURL(path string, defaultSchema charm.Schema) (*charm.URL, error) {
 	var err error
 	path, err = charm.EnsureSchema(path, defaultSchema)
@@ -242,10 +641,3 @@ func charmValidationError(name string, err error) error {
 	}
 	return nil
 }
-
-func GetModelConstraints(caller base.APICallCloser) (constraints.Value, error) {
-	_, backend := base.NewClientFacade(caller, "Client")
-	results := new(params.GetConstraintsResults)
-	err := backend.FacadeCall("GetModelConstraints", nil, results)
-	return results.Constraints, err
-}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/core/devices"
+	"github.com/juju/juju/storage"
+)
+
+const testCharmMeta = `
+name: app
+summary: test charm
+description: test charm
+provides:
+  website:
+    interface: http
+requires:
+  db:
+    interface: mysql
+extra-bindings:
+  data:
+storage:
+  disks:
+    type: block
+devices:
+  gpu:
+    type: nvidia.com/gpu
+resources:
+  image:
+    type: file
+    filename: image.tar
+`
+
+const testCharmConfig = `
+options:
+  name:
+    type: string
+    default: app
+  count:
+    type: int
+    default: 1
+`
+
+func TestValidateDeployArgs(t *testing.T) {
+	meta, err := charm.ReadMeta(strings.NewReader(testCharmMeta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := charm.ReadConfig(strings.NewReader(testCharmConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   DeployArgs
+		config map[string]string
+		err    string
+	}{{
+		name:   "no arguments",
+		config: map[string]string{},
+	}, {
+		name: "config",
+		args: DeployArgs{
+			Config: map[string]interface{}{
+				"name":  "web",
+				"count": 3,
+			},
+			Trust: true,
+		},
+		config: map[string]string{
+			"name":  "web",
+			"count": "3",
+			"trust": "true",
+		},
+	}, {
+		name: "unknown config option",
+		args: DeployArgs{
+			Config: map[string]interface{}{
+				"colour": "blue",
+			},
+		},
+		err: "charm config",
+	}, {
+		name: "config of the wrong type",
+		args: DeployArgs{
+			Config: map[string]interface{}{
+				"count": "many",
+			},
+		},
+		err: "charm config",
+	}, {
+		name: "bindings",
+		args: DeployArgs{
+			EndpointBindings: map[string]string{
+				"":        "alpha",
+				"website": "public",
+				"db":      "internal",
+				"data":    "storage",
+			},
+		},
+		config: map[string]string{},
+	}, {
+		name: "unknown binding",
+		args: DeployArgs{
+			EndpointBindings: map[string]string{
+				"metrics": "alpha",
+			},
+		},
+		err: `binding for endpoint "metrics" not defined by charm "app"`,
+	}, {
+		name: "storage, devices and resources",
+		args: DeployArgs{
+			Storage: map[string]storage.Constraints{
+				"disks": {Count: 1},
+			},
+			Devices: map[string]devices.Constraints{
+				"gpu": {Count: 1},
+			},
+			Resources: map[string]string{
+				"image": "3",
+			},
+		},
+		config: map[string]string{},
+	}, {
+		name: "unknown storage",
+		args: DeployArgs{
+			Storage: map[string]storage.Constraints{
+				"logs": {Count: 1},
+			},
+		},
+		err: `storage "logs" not defined by charm "app"`,
+	}, {
+		name: "unknown device",
+		args: DeployArgs{
+			Devices: map[string]devices.Constraints{
+				"tpu": {Count: 1},
+			},
+		},
+		err: `device "tpu" not defined by charm "app"`,
+	}, {
+		name: "unknown resource",
+		args: DeployArgs{
+			Resources: map[string]string{
+				"snap": "1",
+			},
+		},
+		err: `resource "snap" not defined by charm "app"`,
+	}, {
+		name: "attach storage to several units",
+		args: DeployArgs{
+			NumUnits:      2,
+			AttachStorage: []string{"disks/0"},
+		},
+		err: "attaching storage to 2 units",
+	}}
+	for _, test := range tests {
+		config, err := validateDeployArgs(meta, schema, test.args)
+		if test.err != "" {
+			if !errors.IsNotValid(err) || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got %v, want a NotValid error containing %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, test.config) {
+			t.Errorf("%s: got %v, want %v", test.name, config, test.config)
+		}
+	}
+
+	if _, err := validateDeployArgs(nil, schema, DeployArgs{}); !errors.IsNotValid(err) {
+		t.Errorf("without metadata: got %v, want a NotValid error", err)
+	}
+}
+
+func TestIsLocalCharmPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		local bool
+	}{
+		{name: "ubuntu"},
+		{name: "ch:ubuntu"},
+		{name: "cs:~user/focal/ubuntu-3"},
+		{name: "ubuntu/charm"},
+		{name: "./ubuntu", local: true},
+		{name: "../charms/ubuntu.charm", local: true},
+		{name: ".", local: true},
+		{name: "/srv/charms/ubuntu", local: true},
+		{name: "local:ubuntu", local: true},
+		{name: "local:charms/ubuntu", local: true},
+	}
+	for _, test := range tests {
+		if got := isLocalCharmPath(test.name); got != test.local {
+			t.Errorf("%q: got %v, want %v", test.name, got, test.local)
+		}
+	}
+}
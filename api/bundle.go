@@ -0,0 +1,500 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+
+	apibundle "github.com/juju/juju/api/bundle"
+	apicharms "github.com/juju/juju/api/charms"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/cmd/juju/application/utils"
+	"github.com/juju/juju/core/constraints"
+	"github.com/juju/juju/core/devices"
+	"github.com/juju/juju/core/model"
+	"github.com/juju/juju/storage"
+)
+
+// BundleSource identifies a bundle or overlay. Exactly one field must be
+// set.
+type BundleSource struct {
+	// URL is a bundle in CharmHub, such as "ch:kubeflow" or "kubeflow".
+	// It can't be used for overlays.
+	URL string
+	// Path is a bundle YAML file, a bundle archive or a directory holding
+	// a bundle.yaml. Relative charm and resource paths in the bundle are
+	// relative to it.
+	Path string
+	// Data is an in-memory bundle. Relative charm and resource paths in
+	// it are relative to the working directory.
+	Data *charm.BundleData
+}
+
+// DeployBundleArgs holds the options for deploying a bundle.
+type DeployBundleArgs struct {
+	// Channel is the channel to resolve a CharmHub bundle from. It doesn't
+	// apply to the bundle's charms, which use their own channels.
+	Channel charm.Channel
+	// Overlays are merged on top of the bundle, in order.
+	Overlays []BundleSource
+	// Trust grants access to the cloud credential to the applications
+	// that the bundle marks as requiring it.
+	Trust bool
+	// Force deploys charms to series they don't declare support for.
+	Force bool
+	// OnPlan, if set, is called with the full plan before any change is
+	// made. Returning an error aborts the deployment.
+	OnPlan func([]BundleChange) error
+	// OnChange, if set, is called as each change is applied.
+	OnChange func(BundleChange)
+}
+
+// BundleChange describes a single step in deploying a bundle.
+type BundleChange struct {
+	// ID identifies the change within the plan.
+	ID string
+	// Method is the kind of change, such as "addCharm", "deploy",
+	// "addMachines", "addUnit" or "addRelation".
+	Method string
+	// Requires holds the IDs of the changes that must be applied first.
+	Requires []string
+	// Description is a human readable summary of the change.
+	Description string
+	// Result is the entity the change created, such as a charm URL,
+	// application name, machine ID or unit name. It's set once the change
+	// has been applied.
+	Result string
+	// Err is the error the change failed with, if any.
+	Err error
+
+	args map[string]interface{}
+}
+
+// DeployBundle deploys a bundle, along with any overlays, to the model.
+// The change plan is computed by the controller and then applied in
+// dependency order: charms, applications, machines, units, relations,
+// offers and annotations. The plan is computed as if for an empty model,
+// so none of the bundle's applications may already exist.
+//
+// The applied changes are returned. If a change fails, the changes applied
+// so far are returned along with the error.
+func (s *ApplicationsAPI) DeployBundle(ctx context.Context, modelName string, source BundleSource, args DeployBundleArgs) ([]BundleChange, error) {
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	deployCtx, err := newDeployContext(apiRoot)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	data, basePath, bundleURL, err := readBundle(ctx, deployCtx, source, args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	status, err := fullStatus(apiRoot, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := checkBundleModel(data, status); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	modelTag, ok := apiRoot.ModelTag()
+	if !ok {
+		return nil, errors.Errorf("connection to model %q without a model tag", modelName)
+	}
+
+	localCharms, err := substituteLocalCharms(data, basePath)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	plan, err := bundlePlan(apibundle.NewClient(apiRoot), data, bundleURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if args.OnPlan != nil {
+		if err := args.OnPlan(plan); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	deployer := &bundleDeployer{
+		api:         s,
+		ctx:         ctx,
+		deployCtx:   deployCtx,
+		data:        data,
+		basePath:    basePath,
+		localCharms: localCharms,
+		modelName:   status.Model.Name,
+		modelUUID:   modelTag.Id(),
+		trust:       args.Trust,
+		force:       args.Force,
+		results:     make(map[string]string),
+		origins:     make(map[string]charmOrigin),
+	}
+	applied := make([]BundleChange, 0, len(plan))
+	for _, change := range plan {
+		change.Result, change.Err = deployer.apply(change)
+		applied = append(applied, change)
+		if args.OnChange != nil {
+			args.OnChange(change)
+		}
+		if change.Err != nil {
+			return applied, errors.Annotatef(change.Err, "applying %s", change.ID)
+		}
+	}
+	return applied, nil
+}
+
+// readBundle reads the bundle and merges the overlays on top of it,
+// returning the verified bundle data, the directory relative paths in it
+// are resolved against and the bundle URL, if it came from a store.
+func readBundle(ctx context.Context, deployCtx deployContext, source BundleSource, args DeployBundleArgs) (*charm.BundleData, string, string, error) {
+	var (
+		base      charm.BundleDataSource
+		bundleURL string
+		err       error
+	)
+	if source.URL != "" {
+		var cleanup func()
+		base, bundleURL, cleanup, err = storeBundleDataSource(ctx, deployCtx, source.URL, args.Channel)
+		if err != nil {
+			return nil, "", "", errors.Trace(err)
+		}
+		defer cleanup()
+	} else {
+		base, err = localBundleDataSource(source)
+		if err != nil {
+			return nil, "", "", errors.Trace(err)
+		}
+	}
+
+	sources := []charm.BundleDataSource{base}
+	for i, overlay := range args.Overlays {
+		if overlay.URL != "" {
+			return nil, "", "", errors.NotValidf("overlay %d from a store URL", i)
+		}
+		ds, err := localBundleDataSource(overlay)
+		if err != nil {
+			return nil, "", "", errors.Annotatef(err, "reading overlay %d", i)
+		}
+		sources = append(sources, ds)
+	}
+
+	data, err := charm.ReadAndMergeBundleData(sources...)
+	if err != nil {
+		return nil, "", "", errors.Trace(err)
+	}
+	if err := verifyBundle(data, base.BasePath()); err != nil {
+		return nil, "", "", errors.Trace(err)
+	}
+	return data, base.BasePath(), bundleURL, nil
+}
+
+// localBundleDataSource returns a data source for a bundle on disk or in
+// memory.
+func localBundleDataSource(source BundleSource) (charm.BundleDataSource, error) {
+	switch {
+	case source.Path != "" && source.Data != nil:
+		return nil, errors.NotValidf("bundle with both a path and data")
+	case source.Path != "":
+		ds, err := charm.LocalBundleDataSource(source.Path)
+		return ds, errors.Annotatef(err, "reading bundle %q", source.Path)
+	case source.Data != nil:
+		content, err := yaml.Marshal(source.Data)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		ds, err := charm.StreamBundleDataSource(bytes.NewReader(content), "")
+		return ds, errors.Trace(err)
+	default:
+		return nil, errors.NotValidf("empty bundle source")
+	}
+}
+
+// storeBundleDataSource resolves a bundle in CharmHub and downloads it,
+// returning a data source for it along with its resolved URL. The returned
+// cleanup function removes the downloaded archive.
+func storeBundleDataSource(ctx context.Context, deployCtx deployContext, name string, channel charm.Channel) (charm.BundleDataSource, string, func(), error) {
+	if deployCtx.CharmAPIClient.BestAPIVersion() < 3 {
+		return nil, "", nil, errors.NotSupportedf("deploying store bundles on this controller")
+	}
+	bundleURL, err := resolveCharmURL(name, charm.CharmHub)
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+	if !charm.CharmHub.Matches(bundleURL.Schema) {
+		return nil, "", nil, errors.NotSupportedf("bundles from %q", bundleURL.Schema)
+	}
+
+	modelConstraints, err := modelConstraints(deployCtx.APIRoot)
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+	platform, err := utils.DeducePlatform(constraints.Value{}, "", modelConstraints)
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+	origin, err := utils.DeduceOrigin(bundleURL, channel, platform)
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+
+	resolved, err := deployCtx.CharmAPIClient.ResolveCharms([]apicharms.CharmToResolve{{URL: bundleURL, Origin: origin}})
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+	if len(resolved) != 1 {
+		return nil, "", nil, errors.Errorf("expected only one resolution, received %d", len(resolved))
+	}
+	if resolved[0].Error != nil {
+		return nil, "", nil, errors.Trace(resolved[0].Error)
+	}
+	selected := resolved[0]
+	if selected.Origin.Type != "bundle" && selected.URL.Series != "bundle" {
+		return nil, "", nil, errors.NotValidf("%q is a charm, not a bundle", name)
+	}
+
+	info, err := deployCtx.CharmAPIClient.GetDownloadInfo(selected.URL, selected.Origin, nil)
+	if err != nil {
+		return nil, "", nil, errors.Trace(err)
+	}
+	path, err := downloadBundle(ctx, info.URL)
+	if err != nil {
+		return nil, "", nil, errors.Annotatef(err, "downloading bundle %q", name)
+	}
+	cleanup := func() { _ = os.Remove(path) }
+
+	ds, err := charm.LocalBundleDataSource(path)
+	if err != nil {
+		cleanup()
+		return nil, "", nil, errors.Trace(err)
+	}
+	return ds, selected.URL.String(), cleanup, nil
+}
+
+// downloadBundle downloads a bundle archive to a temporary file, returning
+// its path.
+func downloadBundle(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected response %q", resp.Status)
+	}
+
+	file, err := ioutil.TempFile("", "bundle-*.bundle")
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", errors.Trace(err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", errors.Trace(err)
+	}
+	return file.Name(), nil
+}
+
+// verifyBundle checks the merged bundle, including that any local charm
+// paths exist.
+func verifyBundle(data *charm.BundleData, basePath string) error {
+	verifyConstraints := func(s string) error {
+		_, err := constraints.Parse(s)
+		return err
+	}
+	verifyStorage := func(s string) error {
+		_, err := storage.ParseConstraints(s)
+		return err
+	}
+	verifyDevices := func(s string) error {
+		_, err := devices.ParseConstraints(s)
+		return err
+	}
+
+	var err error
+	if basePath == "" {
+		err = data.Verify(verifyConstraints, verifyStorage, verifyDevices)
+	} else {
+		err = data.VerifyLocal(basePath, verifyConstraints, verifyStorage, verifyDevices)
+	}
+	if verr, ok := errors.Cause(err).(*charm.VerificationError); ok {
+		msgs := make([]string, len(verr.Errors))
+		for i, err := range verr.Errors {
+			msgs[i] = err.Error()
+		}
+		return errors.NewNotValid(nil, "bundle: "+strings.Join(msgs, "; "))
+	}
+	return errors.Trace(err)
+}
+
+// checkBundleModel checks that the bundle can be deployed to the model.
+func checkBundleModel(data *charm.BundleData, status *params.FullStatus) error {
+	isKubernetesBundle := data.Type == "kubernetes"
+	isCAASModel := status.Model.Type == model.CAAS.String()
+	if isKubernetesBundle != isCAASModel {
+		return errors.NotValidf("%s bundle on %s model", bundleType(data), status.Model.Type)
+	}
+	for name := range data.Applications {
+		if _, ok := status.Applications[name]; ok {
+			return errors.AlreadyExistsf("application %q", name)
+		}
+	}
+	return nil
+}
+
+func bundleType(data *charm.BundleData) string {
+	if data.Type == "" {
+		return "machine"
+	}
+	return data.Type
+}
+
+// substituteLocalCharms replaces the paths of local charms in the bundle
+// with "local:" URLs, as the controller can't read the charms when planning
+// the changes. It returns the paths keyed by the URLs that replaced them.
+func substituteLocalCharms(data *charm.BundleData, basePath string) (map[string]string, error) {
+	localCharms := make(map[string]string)
+	paths := make(map[string]string)
+	for _, name := range sortedApplications(data) {
+		spec := data.Applications[name]
+		if !isLocalCharmPath(spec.Charm) {
+			continue
+		}
+		path := strings.TrimPrefix(spec.Charm, charm.Local.Prefix(""))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(basePath, path)
+		}
+		if placeholder, ok := paths[path]; ok {
+			spec.Charm = placeholder
+			continue
+		}
+
+		ch, err := charm.ReadCharm(path)
+		if err != nil {
+			return nil, errors.Annotatef(err, "reading charm for application %q", name)
+		}
+		placeholder := charm.Local.Prefix(ch.Meta().Name)
+		if other, ok := localCharms[placeholder]; ok {
+			return nil, errors.NotValidf("charms %q and %q with the same name", other, path)
+		}
+		localCharms[placeholder] = path
+		paths[path] = placeholder
+		spec.Charm = placeholder
+	}
+	return localCharms, nil
+}
+
+func sortedApplications(data *charm.BundleData) []string {
+	names := make([]string, 0, len(data.Applications))
+	for name := range data.Applications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bundlePlan asks the controller for the changes needed to deploy the
+// bundle, in dependency order.
+func bundlePlan(client *apibundle.Client, data *charm.BundleData, bundleURL string) ([]BundleChange, error) {
+	content, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	results, err := client.GetChangesMapArgs(bundleURL, string(content))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(results.Errors) > 0 {
+		return nil, errors.NewNotValid(nil, "bundle: "+strings.Join(results.Errors, "; "))
+	}
+
+	plan := make([]BundleChange, len(results.Changes))
+	for i, change := range results.Changes {
+		plan[i] = BundleChange{
+			ID:          change.Id,
+			Method:      change.Method,
+			Requires:    change.Requires,
+			Description: describeBundleChange(change.Method, change.Args),
+			args:        change.Args,
+		}
+	}
+	return plan, nil
+}
+
+// describeBundleChange returns a summary of a change, in the same terms as
+// "juju deploy" uses.
+func describeBundleChange(method string, args map[string]interface{}) string {
+	arg := func(key string) string {
+		if value, ok := args[key]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+		return ""
+	}
+	switch method {
+	case "addCharm":
+		return "upload charm " + arg("charm")
+	case "deploy":
+		return fmt.Sprintf("deploy application %s using %s", arg("application"), arg("charm"))
+	case "addMachines":
+		if containerType := arg("container-type"); containerType != "" {
+			return "add " + containerType + " container"
+		}
+		return "add new machine"
+	case "addUnit":
+		if to := arg("to"); to != "" {
+			return fmt.Sprintf("add unit of %s to %s", arg("application"), to)
+		}
+		return fmt.Sprintf("add unit of %s to new machine", arg("application"))
+	case "addRelation":
+		return fmt.Sprintf("add relation %s - %s", arg("endpoint1"), arg("endpoint2"))
+	case "expose":
+		return "expose " + arg("application")
+	case "scale":
+		return fmt.Sprintf("scale %s to %s units", arg("application"), arg("scale"))
+	case "setAnnotations":
+		return fmt.Sprintf("set annotations for %s", arg("id"))
+	case "createOffer":
+		return fmt.Sprintf("create offer %s using %s", arg("offer-name"), arg("application"))
+	case "consumeOffer":
+		return fmt.Sprintf("consume offer %s at %s", arg("application-name"), arg("url"))
+	case "grantOfferAccess":
+		return fmt.Sprintf("grant user %s %s access to offer %s", arg("user"), arg("access"), arg("offer"))
+	default:
+		return method
+	}
+}
+
+// decodeChangeArgs converts the arguments of a planned change into the
+// given params struct.
+func decodeChangeArgs(args map[string]interface{}, out interface{}) error {
+	content, err := json.Marshal(args)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(json.Unmarshal(content, out))
+}
@@ -0,0 +1,679 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/annotations"
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/api/applicationoffers"
+	commoncharm "github.com/juju/juju/api/common/charm"
+	"github.com/juju/juju/api/machinemanager"
+	"github.com/juju/juju/api/modelmanager"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/cmd/juju/application/utils"
+	"github.com/juju/juju/core/constraints"
+	"github.com/juju/juju/core/crossmodel"
+	"github.com/juju/juju/core/devices"
+	"github.com/juju/juju/core/instance"
+	"github.com/juju/juju/core/model"
+	"github.com/juju/juju/storage"
+)
+
+// unitMachineTimeout is how long to wait for a unit that another unit or
+// container is placed alongside to be assigned a machine.
+const unitMachineTimeout = time.Minute
+
+// charmOrigin is a charm that has been added to the model by a bundle.
+type charmOrigin struct {
+	url    *charm.URL
+	origin commoncharm.Origin
+}
+
+// bundleDeployer applies the changes planned for a bundle, recording the
+// entities each one creates so that later changes can refer to them.
+type bundleDeployer struct {
+	api         *ApplicationsAPI
+	ctx         context.Context
+	deployCtx   deployContext
+	data        *charm.BundleData
+	basePath    string
+	localCharms map[string]string
+	modelName   string
+	modelUUID   string
+	trust       bool
+	force       bool
+
+	// results maps change IDs to the entity they created.
+	results map[string]string
+	// origins maps addCharm change IDs to the charm they added.
+	origins map[string]charmOrigin
+}
+
+// The following mirror the arguments of the changes planned by the
+// controller.
+
+type addCharmParams struct {
+	Charm        string `json:"charm"`
+	Revision     *int   `json:"revision,omitempty"`
+	Series       string `json:"series,omitempty"`
+	Channel      string `json:"channel,omitempty"`
+	Architecture string `json:"architecture,omitempty"`
+}
+
+type addMachineParams struct {
+	Series        string `json:"series,omitempty"`
+	Constraints   string `json:"constraints,omitempty"`
+	ContainerType string `json:"container-type,omitempty"`
+	ParentID      string `json:"parent-id,omitempty"`
+}
+
+type deployParams struct {
+	Charm            string                 `json:"charm"`
+	Series           string                 `json:"series,omitempty"`
+	Application      string                 `json:"application,omitempty"`
+	NumUnits         int                    `json:"num-units,omitempty"`
+	Options          map[string]interface{} `json:"options,omitempty"`
+	Constraints      string                 `json:"constraints,omitempty"`
+	Storage          map[string]string      `json:"storage,omitempty"`
+	Devices          map[string]string      `json:"devices,omitempty"`
+	EndpointBindings map[string]string      `json:"endpoint-bindings,omitempty"`
+	Resources        map[string]int         `json:"resources,omitempty"`
+	LocalResources   map[string]string      `json:"local-resources,omitempty"`
+	Channel          string                 `json:"channel,omitempty"`
+}
+
+type addUnitParams struct {
+	Application string `json:"application"`
+	To          string `json:"to,omitempty"`
+}
+
+type addRelationParams struct {
+	Endpoint1 string `json:"endpoint1"`
+	Endpoint2 string `json:"endpoint2"`
+}
+
+type exposeParams struct {
+	Application      string                             `json:"application"`
+	ExposedEndpoints map[string]*params.ExposedEndpoint `json:"exposed-endpoints,omitempty"`
+}
+
+type scaleParams struct {
+	Application string `json:"application"`
+	Scale       int    `json:"scale"`
+}
+
+type setAnnotationsParams struct {
+	ID          string            `json:"id"`
+	EntityType  string            `json:"entity-type"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type createOfferParams struct {
+	Application string   `json:"application"`
+	Endpoints   []string `json:"endpoints"`
+	OfferName   string   `json:"offer-name,omitempty"`
+}
+
+type consumeOfferParams struct {
+	URL             string `json:"url"`
+	ApplicationName string `json:"application-name,omitempty"`
+}
+
+type grantOfferAccessParams struct {
+	User   string `json:"user"`
+	Access string `json:"access"`
+	Offer  string `json:"offer"`
+}
+
+// apply applies a single change, returning the entity it created.
+func (d *bundleDeployer) apply(change BundleChange) (string, error) {
+	var (
+		result string
+		err    error
+	)
+	switch change.Method {
+	case "addCharm":
+		result, err = d.addCharm(change)
+	case "addMachines":
+		result, err = d.addMachine(change)
+	case "deploy":
+		result, err = d.deploy(change)
+	case "addUnit":
+		result, err = d.addUnit(change)
+	case "addRelation":
+		err = d.addRelation(change)
+	case "expose":
+		err = d.expose(change)
+	case "scale":
+		err = d.scale(change)
+	case "setAnnotations":
+		err = d.setAnnotations(change)
+	case "createOffer":
+		err = d.createOffer(change)
+	case "consumeOffer":
+		result, err = d.consumeOffer(change)
+	case "grantOfferAccess":
+		err = d.grantOfferAccess(change)
+	default:
+		// Upgrading charms and changing options or constraints are only
+		// planned for applications that already exist.
+		err = errors.NotSupportedf("bundle change %q", change.Method)
+	}
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	d.results[change.ID] = result
+	return result, nil
+}
+
+// resolve replaces a "$<change id>" placeholder with the entity created by
+// that change. Other values are returned as is.
+func (d *bundleDeployer) resolve(value string) (string, error) {
+	if !strings.HasPrefix(value, "$") {
+		return value, nil
+	}
+	result, ok := d.results[value[1:]]
+	if !ok {
+		return "", errors.NotFoundf("result of change %q", value[1:])
+	}
+	return result, nil
+}
+
+// resolveMachine resolves a placeholder to a machine ID. Placeholders that
+// refer to units resolve to the machine the unit is assigned to, waiting for
+// the assignment if needed.
+func (d *bundleDeployer) resolveMachine(value string) (string, error) {
+	entity, err := d.resolve(value)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if !names.IsValidUnit(entity) {
+		return entity, nil
+	}
+
+	applicationName, err := names.UnitApplication(entity)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	var machineID string
+	err = waitForStatus(d.ctx, d.deployCtx.APIRoot, []string{entity}, unitMachineTimeout, func(status *params.FullStatus) (bool, string, error) {
+		unit, ok := status.Applications[applicationName].Units[entity]
+		if !ok || unit.Machine == "" {
+			return false, fmt.Sprintf("unit %q has no machine", entity), nil
+		}
+		machineID = unit.Machine
+		return true, "", nil
+	})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return machineID, nil
+}
+
+func (d *bundleDeployer) addCharm(change BundleChange) (string, error) {
+	var p addCharmParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	args := DeployArgs{
+		Series: p.Series,
+		Force:  d.force,
+	}
+	if args.Series == "" {
+		args.Series = d.data.Series
+	}
+	if err := ensureWorkloadSeries(&args, args.Series, d.deployCtx.ModelConfig); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	var (
+		charmURL *charm.URL
+		origin   commoncharm.Origin
+		err      error
+	)
+	if path, ok := d.localCharms[p.Charm]; ok {
+		ch, err := charm.ReadCharm(path)
+		if err != nil {
+			return "", errors.Annotatef(err, "reading charm %q", path)
+		}
+		charmURL, origin, err = addLocalCharm(d.deployCtx, ch, args)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+	} else {
+		charmURL, origin, err = d.bundleCharmOrigin(p, args)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		charmURL, origin, err = addStoreCharm(d.deployCtx, charmURL, origin, args)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+
+	d.origins[change.ID] = charmOrigin{url: charmURL, origin: origin}
+	return charmURL.String(), nil
+}
+
+// bundleCharmOrigin returns the URL and origin to resolve a store charm in
+// the bundle with.
+func (d *bundleDeployer) bundleCharmOrigin(p addCharmParams, args DeployArgs) (*charm.URL, commoncharm.Origin, error) {
+	charmURL, err := resolveCharmURL(p.Charm, defaultCharmSchema(d.deployCtx))
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	revision := -1
+	if p.Revision != nil {
+		revision = *p.Revision
+	}
+
+	channel := charm.Channel{}
+	if p.Channel != "" {
+		if channel, err = charm.ParseChannelNormalize(p.Channel); err != nil {
+			return nil, commoncharm.Origin{}, errors.Trace(err)
+		}
+	} else if charm.CharmHub.Matches(charmURL.Schema) {
+		channel = charm.Channel{Risk: charm.Stable}
+	}
+
+	var cons constraints.Value
+	if p.Architecture != "" {
+		cons.Arch = &p.Architecture
+	}
+	charmURL, origin, err := storeOrigin(d.deployCtx, charmURL, revision, channel, cons, args.Series)
+	return charmURL, origin, errors.Trace(err)
+}
+
+func (d *bundleDeployer) addMachine(change BundleChange) (string, error) {
+	var p addMachineParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return "", errors.Trace(err)
+	}
+
+	cons, err := constraints.Parse(p.Constraints)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	machineParams := params.AddMachineParams{
+		Series:      p.Series,
+		Constraints: cons,
+		Jobs:        []model.MachineJob{model.JobHostUnits},
+	}
+	if machineParams.Series == "" {
+		machineParams.Series = d.data.Series
+	}
+	if p.ContainerType != "" {
+		containerType := p.ContainerType
+		if containerType == "lxc" {
+			containerType = string(instance.LXD)
+		}
+		if machineParams.ContainerType, err = instance.ParseContainerType(containerType); err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+	if p.ParentID != "" {
+		parentID, err := d.resolveMachine(p.ParentID)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		// Containers are always created on the top level machine.
+		machineParams.ParentId = strings.SplitN(parentID, "/", 2)[0]
+	}
+
+	results, err := machinemanager.NewClient(d.deployCtx.APIRoot).AddMachines([]params.AddMachineParams{machineParams})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(results) != 1 {
+		return "", errors.Errorf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Error != nil {
+		return "", errors.Trace(results[0].Error)
+	}
+	return results[0].Machine, nil
+}
+
+func (d *bundleDeployer) deploy(change BundleChange) (string, error) {
+	var p deployParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return "", errors.Trace(err)
+	}
+	added, ok := d.origins[strings.TrimPrefix(p.Charm, "$")]
+	if !ok {
+		return "", errors.NotFoundf("charm for application %q", p.Application)
+	}
+
+	args := DeployArgs{
+		ApplicationName:  p.Application,
+		Config:           p.Options,
+		EndpointBindings: p.EndpointBindings,
+		Force:            d.force,
+	}
+	var err error
+	if args.Constraints, err = constraints.Parse(p.Constraints); err != nil {
+		return "", errors.Trace(err)
+	}
+	if spec := d.data.Applications[p.Application]; spec != nil && spec.RequiresTrust {
+		args.Trust = d.trust
+	}
+	if trust, ok := p.Options[trustConfigOptionName].(bool); ok {
+		delete(args.Config, trustConfigOptionName)
+		args.Trust = args.Trust || trust
+	}
+	// Machine units are added by separate changes, while kubernetes units
+	// are created by the deploy itself.
+	if d.data.Type == "kubernetes" {
+		args.NumUnits = p.NumUnits
+	}
+
+	if len(p.Storage) > 0 {
+		args.Storage = make(map[string]storage.Constraints, len(p.Storage))
+		for name, value := range p.Storage {
+			if args.Storage[name], err = storage.ParseConstraints(value); err != nil {
+				return "", errors.Annotatef(err, "storage %q", name)
+			}
+		}
+	}
+	if len(p.Devices) > 0 {
+		args.Devices = make(map[string]devices.Constraints, len(p.Devices))
+		for name, value := range p.Devices {
+			if args.Devices[name], err = devices.ParseConstraints(value); err != nil {
+				return "", errors.Annotatef(err, "device %q", name)
+			}
+		}
+	}
+	if len(p.Resources)+len(p.LocalResources) > 0 {
+		args.Resources = make(map[string]string, len(p.Resources)+len(p.LocalResources))
+		for name, revision := range p.Resources {
+			args.Resources[name] = strconv.Itoa(revision)
+		}
+		for name, path := range p.LocalResources {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(d.basePath, path)
+			}
+			args.Resources[name] = path
+		}
+	}
+
+	if err := d.api.deployCharm(d.deployCtx, added.url, added.origin, args); err != nil {
+		return "", errors.Trace(err)
+	}
+	return p.Application, nil
+}
+
+func (d *bundleDeployer) addUnit(change BundleChange) (string, error) {
+	var p addUnitParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return "", errors.Trace(err)
+	}
+	applicationName, err := d.resolve(p.Application)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	unitParams := application.AddUnitsParams{
+		ApplicationName: applicationName,
+		NumUnits:        1,
+	}
+	if p.To != "" {
+		machineID, err := d.resolveMachine(p.To)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		placement, err := utils.ParsePlacement(machineID)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		unitParams.Placement = []*instance.Placement{placement}
+	}
+
+	units, err := d.deployCtx.ApplicationAPIClient.AddUnits(unitParams)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(units) != 1 {
+		return "", errors.Errorf("expected 1 unit, got %d", len(units))
+	}
+	return units[0], nil
+}
+
+func (d *bundleDeployer) addRelation(change BundleChange) error {
+	var p addRelationParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+	endpoints := make([]string, 2)
+	for i, endpoint := range []string{p.Endpoint1, p.Endpoint2} {
+		// Endpoints are given as "$<change id>:<relation>".
+		parts := strings.SplitN(endpoint, ":", 2)
+		applicationName, err := d.resolve(parts[0])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		parts[0] = applicationName
+		endpoints[i] = strings.Join(parts, ":")
+	}
+
+	_, err := d.deployCtx.ApplicationAPIClient.AddRelation(endpoints, nil)
+	if params.IsCodeAlreadyExists(err) {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+func (d *bundleDeployer) expose(change BundleChange) error {
+	var p exposeParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+	applicationName, err := d.resolve(p.Application)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var exposedEndpoints map[string]params.ExposedEndpoint
+	if len(p.ExposedEndpoints) > 0 {
+		exposedEndpoints = make(map[string]params.ExposedEndpoint, len(p.ExposedEndpoints))
+		for name, endpoint := range p.ExposedEndpoints {
+			if endpoint != nil {
+				exposedEndpoints[name] = *endpoint
+			}
+		}
+	}
+	return errors.Trace(d.deployCtx.ApplicationAPIClient.Expose(applicationName, exposedEndpoints))
+}
+
+func (d *bundleDeployer) scale(change BundleChange) error {
+	var p scaleParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+	applicationName, err := d.resolve(p.Application)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	result, err := d.deployCtx.ApplicationAPIClient.ScaleApplication(application.ScaleApplicationParams{
+		ApplicationName: applicationName,
+		Scale:           p.Scale,
+		Force:           d.force,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if result.Error != nil {
+		return errors.Trace(result.Error)
+	}
+	return nil
+}
+
+func (d *bundleDeployer) setAnnotations(change BundleChange) error {
+	var p setAnnotationsParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+	id, err := d.resolve(p.ID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var tag names.Tag
+	switch p.EntityType {
+	case "machine":
+		tag = names.NewMachineTag(id)
+	case "application":
+		tag = names.NewApplicationTag(id)
+	default:
+		return errors.NotValidf("annotations for entity type %q", p.EntityType)
+	}
+
+	results, err := annotations.NewClient(d.deployCtx.APIRoot).Set(map[string]map[string]string{
+		tag.String(): p.Annotations,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return errors.Trace(result.Error)
+		}
+	}
+	return nil
+}
+
+func (d *bundleDeployer) createOffer(change BundleChange) error {
+	var p createOfferParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+	applicationName, err := d.resolve(p.Application)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	client, err := d.offersClient()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = client.Close() }()
+	results, err := client.Offer(d.modelUUID, applicationName, p.Endpoints, p.OfferName, "")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return errors.Trace(result.Error)
+		}
+	}
+	return nil
+}
+
+func (d *bundleDeployer) consumeOffer(change BundleChange) (string, error) {
+	var p consumeOfferParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return "", errors.Trace(err)
+	}
+	url, err := crossmodel.ParseOfferURL(p.URL)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if url.HasEndpoint() {
+		return "", errors.NotValidf("offer URL %q with an endpoint", p.URL)
+	}
+	if url.Source != "" {
+		return "", errors.NotSupportedf("consuming offer %q from another controller", p.URL)
+	}
+
+	client, err := d.offersClient()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer func() { _ = client.Close() }()
+	details, err := client.GetConsumeDetails(url.AsLocal().String())
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if details.Offer == nil {
+		return "", errors.NotFoundf("offer %q", p.URL)
+	}
+	localName, err := d.deployCtx.ApplicationAPIClient.Consume(crossmodel.ConsumeApplicationArgs{
+		Offer:            *details.Offer,
+		ApplicationAlias: p.ApplicationName,
+		Macaroon:         details.Macaroon,
+	})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return localName, nil
+}
+
+func (d *bundleDeployer) grantOfferAccess(change BundleChange) error {
+	var p grantOfferAccessParams
+	if err := decodeChangeArgs(change.args, &p); err != nil {
+		return errors.Trace(err)
+	}
+
+	owner, err := d.modelOwner()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client, err := d.offersClient()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = client.Close() }()
+	err = client.GrantOffer(p.User, p.Access, crossmodel.MakeURL(owner, d.modelName, p.Offer, ""))
+	if err != nil && params.IsCodeAlreadyExists(err) {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// modelOwner returns the name of the user that owns the model, which
+// qualifies the URLs of the model's offers.
+func (d *bundleDeployer) modelOwner() (string, error) {
+	controllerRoot, err := d.api.client.NewAPIRootContext(d.ctx)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	modelAPI := modelmanager.NewClient(controllerRoot)
+	defer func() { _ = modelAPI.Close() }()
+
+	results, err := modelAPI.ModelInfo([]names.ModelTag{names.NewModelTag(d.modelUUID)})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(results) != 1 {
+		return "", errors.Errorf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Error != nil {
+		return "", errors.Trace(results[0].Error)
+	}
+	if results[0].Result == nil {
+		return "", errors.NotFoundf("model %q", d.modelName)
+	}
+	ownerTag, err := names.ParseUserTag(results[0].Result.OwnerTag)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return ownerTag.Id(), nil
+}
+
+// offersClient returns a client for the controller's offers facade.
+func (d *bundleDeployer) offersClient() (*applicationoffers.Client, error) {
+	controllerRoot, err := d.api.client.NewAPIRootContext(d.ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return applicationoffers.NewClient(controllerRoot), nil
+}
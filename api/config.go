@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/model"
+
+	"github.com/SimonRichardson/juju-api-example/client"
+)
+
+// ConfigValue describes a charm config option along with its current value.
+// Values are typed according to the option type: string, int64, float64 or
+// bool. A nil value means the option has no value.
+type ConfigValue struct {
+	Value       interface{}
+	Default     interface{}
+	Type        string
+	Description string
+	// Source is where the value comes from, either "default", "user" or
+	// "unset".
+	Source string
+}
+
+// GetConfig returns the charm config of the application, keyed by option
+// name.
+func (s *ApplicationsAPI) GetConfig(ctx context.Context, modelName, applicationName string) (map[string]ConfigValue, error) {
+	applicationAPIClient, err := applicationClient(ctx, s.client, modelName, applicationName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = applicationAPIClient.Close() }()
+	results, err := applicationAPIClient.Get(model.GenerationMaster, applicationName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	schema, err := charmConfigSchema(results)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	values := make(map[string]ConfigValue, len(schema.Options))
+	for name, option := range schema.Options {
+		attrs, _ := results.CharmConfig[name].(map[string]interface{})
+		value, err := typedConfigValue(schema, name, attrs["value"])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		source, _ := attrs["source"].(string)
+		values[name] = ConfigValue{
+			Value:       value,
+			Default:     option.Default,
+			Type:        option.Type,
+			Description: option.Description,
+			Source:      source,
+		}
+	}
+	return values, nil
+}
+
+// SetConfig sets charm config options on the application. The values are
+// validated against the charm's config schema before being sent, so unknown
+// options and values of the wrong type are rejected without changing the
+// application. Use ResetConfig to return options to their defaults.
+func (s *ApplicationsAPI) SetConfig(ctx context.Context, modelName, applicationName string, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return errors.NotValidf("empty config")
+	}
+
+	applicationAPIClient, err := applicationClient(ctx, s.client, modelName, applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = applicationAPIClient.Close() }()
+	results, err := applicationAPIClient.Get(model.GenerationMaster, applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	schema, err := charmConfigSchema(results)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	settings, err := schema.ValidateSettings(charm.Settings(values))
+	if err != nil {
+		return errors.NewNotValid(err, "invalid config")
+	}
+	config := make(map[string]string, len(settings))
+	for name, value := range settings {
+		if value == nil {
+			return errors.NotValidf("nil value for option %q, use ResetConfig", name)
+		}
+		config[name] = formatConfigValue(value)
+	}
+
+	if applicationAPIClient.BestAPIVersion() < 13 {
+		return errors.Trace(applicationAPIClient.SetApplicationConfig(model.GenerationMaster, applicationName, config))
+	}
+	return errors.Trace(applicationAPIClient.SetConfig(model.GenerationMaster, applicationName, "", config))
+}
+
+// ResetConfig returns the given charm config options to their defaults.
+func (s *ApplicationsAPI) ResetConfig(ctx context.Context, modelName, applicationName string, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.NotValidf("empty config keys")
+	}
+
+	applicationAPIClient, err := applicationClient(ctx, s.client, modelName, applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = applicationAPIClient.Close() }()
+	results, err := applicationAPIClient.Get(model.GenerationMaster, applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	schema, err := charmConfigSchema(results)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, key := range keys {
+		if _, ok := schema.Options[key]; !ok {
+			return errors.NotValidf("unknown option %q", key)
+		}
+	}
+
+	return errors.Trace(applicationAPIClient.UnsetApplicationConfig(model.GenerationMaster, applicationName, keys))
+}
+
+// applicationClient returns an application facade client for the model,
+// after validating the application name. The client must be closed once
+// it's no longer needed.
+func applicationClient(ctx context.Context, c *client.Client, modelName, applicationName string) (*application.Client, error) {
+	if !names.IsValidApplication(applicationName) {
+		return nil, errors.NotValidf("application name %q", applicationName)
+	}
+	apiRoot, err := c.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return application.NewClient(apiRoot), nil
+}
+
+// charmConfigSchema rebuilds the charm's config schema from the option
+// descriptions returned by the application Get call.
+func charmConfigSchema(results *params.ApplicationGetResults) (*charm.Config, error) {
+	schema := charm.NewConfig()
+	for name, value := range results.CharmConfig {
+		attrs, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("unexpected config format for option %q", name)
+		}
+		option := charm.Option{}
+		option.Type, _ = attrs["type"].(string)
+		option.Description, _ = attrs["description"].(string)
+		// The option's type must be known before its default can be
+		// coerced to it.
+		schema.Options[name] = option
+
+		defaultValue, err := typedConfigValue(schema, name, attrs["default"])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		option.Default = defaultValue
+		schema.Options[name] = option
+	}
+	return schema, nil
+}
+
+// typedConfigValue coerces a value decoded from the API into the type of
+// the named option. Numbers are decoded as float64, so whole numbers are
+// converted before validating int options.
+func typedConfigValue(schema *charm.Config, name string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if f, ok := value.(float64); ok && schema.Options[name].Type == "int" {
+		value = int64(f)
+	}
+	settings, err := schema.ValidateSettings(charm.Settings{name: value})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return settings[name], nil
+}
+
+func formatConfigValue(value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
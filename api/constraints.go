@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+	"github.com/juju/juju/core/constraints"
+
+	"github.com/SimonRichardson/juju-api-example/client"
+)
+
+type ConstraintsAPI struct {
+	client *client.Client
+}
+
+func NewConstraintsAPI(client *client.Client) *ConstraintsAPI {
+	return &ConstraintsAPI{
+		client: client,
+	}
+}
+
+// ModelConstraints returns the model's constraints.
+func (s *ConstraintsAPI) ModelConstraints(ctx context.Context, modelName string) (constraints.Value, error) {
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return constraints.Value{}, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	cons, err := modelConstraints(apiRoot)
+	return cons, errors.Trace(err)
+}
+
+// SetModelConstraints parses the constraints, such as "mem=4G cores=2",
+// and sets them on the model, replacing any it already has. An empty
+// string clears them.
+func (s *ConstraintsAPI) SetModelConstraints(ctx context.Context, modelName, cons string) error {
+	value, err := parseConstraints(cons)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	return errors.Trace(callContext(apiRoot.Context(), func() error {
+		return apiRoot.Client().SetModelConstraints(value)
+	}))
+}
+
+// ApplicationConstraints returns the constraints set on the application.
+// They don't include the model's; see EffectiveConstraints.
+func (s *ConstraintsAPI) ApplicationConstraints(ctx context.Context, modelName, applicationName string) (constraints.Value, error) {
+	applicationAPIClient, err := applicationClient(ctx, s.client, modelName, applicationName)
+	if err != nil {
+		return constraints.Value{}, errors.Trace(err)
+	}
+	defer func() { _ = applicationAPIClient.Close() }()
+	results, err := applicationAPIClient.GetConstraints(applicationName)
+	if err != nil {
+		return constraints.Value{}, errors.Trace(err)
+	}
+	if len(results) != 1 {
+		return constraints.Value{}, errors.Errorf("expected 1 result, got %d", len(results))
+	}
+	return results[0], nil
+}
+
+// SetApplicationConstraints parses the constraints and sets them on the
+// application, replacing any it already has. They apply to units added
+// from then on. An empty string clears them.
+func (s *ConstraintsAPI) SetApplicationConstraints(ctx context.Context, modelName, applicationName, cons string) error {
+	value, err := parseConstraints(cons)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	applicationAPIClient, err := applicationClient(ctx, s.client, modelName, applicationName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = applicationAPIClient.Close() }()
+	return errors.Trace(applicationAPIClient.SetConstraints(applicationName, value))
+}
+
+// EffectiveConstraints returns the constraints new units of the application
+// get: the application's constraints merged over the model's, as for
+// deploy.
+func (s *ConstraintsAPI) EffectiveConstraints(ctx context.Context, modelName, applicationName string) (constraints.Value, error) {
+	applicationCons, err := s.ApplicationConstraints(ctx, modelName, applicationName)
+	if err != nil {
+		return constraints.Value{}, errors.Trace(err)
+	}
+	modelCons, err := s.ModelConstraints(ctx, modelName)
+	if err != nil {
+		return constraints.Value{}, errors.Trace(err)
+	}
+	return mergeConstraints(modelCons, applicationCons)
+}
+
+// modelConstraints returns the model's constraints.
+func modelConstraints(apiRoot api.Connection) (constraints.Value, error) {
+	var cons constraints.Value
+	err := callContext(apiRoot.Context(), func() error {
+		var err error
+		cons, err = apiRoot.Client().GetModelConstraints()
+		return err
+	})
+	if err != nil {
+		// The call may still be running, so cons mustn't be read.
+		return constraints.Value{}, errors.Trace(err)
+	}
+	return cons, nil
+}
+
+// callContext makes a call through the connection's Client facade, which
+// isn't bound to the connection's context, returning once the context is
+// done without waiting for the call to complete.
+func callContext(ctx context.Context, call func() error) error {
+	if err := ctx.Err(); err != nil {
+		return client.ContextError(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- call()
+	}()
+	select {
+	case err := <-result:
+		return errors.Trace(err)
+	case <-ctx.Done():
+		return client.ContextError(ctx.Err())
+	}
+}
+
+// parseConstraints parses and validates a constraints string.
+func parseConstraints(cons string) (constraints.Value, error) {
+	value, err := constraints.Parse(cons)
+	if err != nil {
+		return constraints.Value{}, errors.NewNotValid(err, "constraints")
+	}
+	return value, nil
+}
+
+// mergeConstraints merges the constraints over the model's. Provider
+// specific conflicts, such as instance-type and mem, are only known to the
+// controller, so the constraints are merged as is.
+func mergeConstraints(modelCons, cons constraints.Value) (constraints.Value, error) {
+	merged, err := constraints.NewValidator().Merge(modelCons, cons)
+	return merged, errors.Trace(err)
+}
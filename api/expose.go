@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/network/firewall"
+)
+
+// ExposedEndpoint holds where an exposed endpoint's ports are reachable
+// from.
+type ExposedEndpoint struct {
+	// Spaces holds the names of the spaces the ports are open to.
+	Spaces []string
+	// CIDRs holds the CIDRs the ports are open to.
+	CIDRs []string
+}
+
+// Expose exposes the application, returning its exposed endpoints once the
+// change is made. The endpoints are keyed by name, and the "" key applies
+// to all of the application's endpoints. An endpoint without spaces or
+// CIDRs is open to all networks, as is every endpoint if none are given.
+//
+// Exposing an endpoint replaces any earlier exposure of it, while other
+// endpoints are left as they are.
+func (s *ApplicationsAPI) Expose(ctx context.Context, modelName, applicationName string, endpoints map[string]ExposedEndpoint) (map[string]ExposedEndpoint, error) {
+	if !names.IsValidApplication(applicationName) {
+		return nil, errors.NotValidf("application name %q", applicationName)
+	}
+	var exposedEndpoints map[string]params.ExposedEndpoint
+	if len(endpoints) > 0 {
+		exposedEndpoints = make(map[string]params.ExposedEndpoint, len(endpoints))
+		for name, endpoint := range endpoints {
+			if err := validateExposedEndpoint(name, endpoint); err != nil {
+				return nil, errors.Trace(err)
+			}
+			exposedEndpoints[name] = params.ExposedEndpoint{
+				ExposeToSpaces: endpoint.Spaces,
+				ExposeToCIDRs:  endpoint.CIDRs,
+			}
+		}
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	if err := application.NewClient(apiRoot).Expose(applicationName, exposedEndpoints); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return exposedEndpointsFromStatus(apiRoot, applicationName)
+}
+
+// Unexpose unexposes the given endpoints of the application, returning the
+// endpoints that remain exposed. Without endpoints, the whole application
+// is unexposed.
+func (s *ApplicationsAPI) Unexpose(ctx context.Context, modelName, applicationName string, endpoints ...string) (map[string]ExposedEndpoint, error) {
+	if !names.IsValidApplication(applicationName) {
+		return nil, errors.NotValidf("application name %q", applicationName)
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	if err := application.NewClient(apiRoot).Unexpose(applicationName, endpoints); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return exposedEndpointsFromStatus(apiRoot, applicationName)
+}
+
+// ExposedEndpoints returns the application's exposed endpoints, keyed as
+// for Expose. It's empty if the application isn't exposed.
+func (s *ApplicationsAPI) ExposedEndpoints(ctx context.Context, modelName, applicationName string) (map[string]ExposedEndpoint, error) {
+	if !names.IsValidApplication(applicationName) {
+		return nil, errors.NotValidf("application name %q", applicationName)
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	return exposedEndpointsFromStatus(apiRoot, applicationName)
+}
+
+func exposedEndpointsFromStatus(caller base.APICaller, applicationName string) (map[string]ExposedEndpoint, error) {
+	status, err := fullStatus(caller, []string{applicationName})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	app, ok := status.Applications[applicationName]
+	if !ok {
+		return nil, errors.NotFoundf("application %q", applicationName)
+	}
+	if !app.Exposed {
+		return nil, nil
+	}
+	if len(app.ExposedEndpoints) == 0 {
+		// Controllers without per-endpoint exposure open every endpoint
+		// to all networks.
+		return map[string]ExposedEndpoint{
+			"": {CIDRs: []string{firewall.AllNetworksIPV4CIDR, firewall.AllNetworksIPV6CIDR}},
+		}, nil
+	}
+
+	result := make(map[string]ExposedEndpoint, len(app.ExposedEndpoints))
+	for name, endpoint := range app.ExposedEndpoints {
+		exposed := ExposedEndpoint{
+			Spaces: append([]string(nil), endpoint.ExposeToSpaces...),
+			CIDRs:  append([]string(nil), endpoint.ExposeToCIDRs...),
+		}
+		sort.Strings(exposed.Spaces)
+		sort.Strings(exposed.CIDRs)
+		result[name] = exposed
+	}
+	return result, nil
+}
+
+func validateExposedEndpoint(name string, endpoint ExposedEndpoint) error {
+	for _, space := range endpoint.Spaces {
+		if !names.IsValidSpace(space) {
+			return errors.NotValidf("space %q for endpoint %q", space, name)
+		}
+	}
+	for _, cidr := range endpoint.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.NotValidf("CIDR %q for endpoint %q", cidr, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"os"
+
+	"github.com/juju/juju/cmd/modelcmd"
+)
+
+// osFilesystem implements modelcmd.Filesystem using the os package, so that
+// resource files can be read from local paths.
+type osFilesystem struct{}
+
+func (osFilesystem) Create(name string) (*os.File, error) {
+	return os.Create(name)
+}
+
+func (osFilesystem) Open(name string) (modelcmd.ReadSeekCloser, error) {
+	return os.Open(name)
+}
+
+func (osFilesystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFilesystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (osFilesystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+	"gopkg.in/juju/environschema.v1"
+
+	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/api/modelconfig"
+	"github.com/juju/juju/api/modelmanager"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/environs/config"
+
+	"github.com/SimonRichardson/juju-api-example/client"
+)
+
+// ModelConfigValue describes a model config attribute along with its
+// current value.
+type ModelConfigValue struct {
+	// Value is the attribute's value, typed according to the config
+	// schema where the attribute is known to it.
+	Value interface{}
+	// Source is where the value comes from: "default", "controller",
+	// "region" or "model".
+	Source string
+	// Type is the schema type of the attribute, such as "string", "int"
+	// or "bool". It's empty for provider specific attributes.
+	Type string
+	// Description describes the attribute, if it's known to the schema.
+	Description string
+}
+
+// ModelDefault holds the default values of a model config attribute at
+// each level that it can be set.
+type ModelDefault struct {
+	// Default is the value built into Juju, if any.
+	Default interface{}
+	// Controller is the value set for the cloud on the controller, if
+	// any.
+	Controller interface{}
+	// Regions holds the values set for each region of the cloud.
+	Regions map[string]interface{}
+}
+
+// readOnlyModelConfigKeys holds the attributes that can't be changed by
+// setting model config, along with how they can be changed.
+var readOnlyModelConfigKeys = map[string]string{
+	config.NameKey:         "they are fixed when the model is created",
+	config.TypeKey:         "they are fixed when the model is created",
+	config.UUIDKey:         "they are fixed when the model is created",
+	config.AgentVersionKey: "it must be set by upgrading the model",
+	config.CharmHubURLKey:  "it must be set when the model is created",
+}
+
+type ModelConfigAPI struct {
+	client *client.Client
+}
+
+func NewModelConfigAPI(client *client.Client) *ModelConfigAPI {
+	return &ModelConfigAPI{
+		client: client,
+	}
+}
+
+// Get returns the model's config, keyed by attribute name.
+func (s *ModelConfigAPI) Get(ctx context.Context, modelName string) (map[string]ModelConfigValue, error) {
+	schema, err := modelConfigSchema()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	modelConfigAPI, err := s.modelConfigClient(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = modelConfigAPI.Close() }()
+	values, err := modelConfigAPI.ModelGetWithMetadata()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	result := make(map[string]ModelConfigValue, len(values))
+	for name, value := range values {
+		// Values the controller holds are valid, but may not match the
+		// schema of this client's version, so they're kept as is.
+		typed, err := typedModelConfigValue(schema, name, value.Value)
+		if err != nil {
+			typed = value.Value
+		}
+		field := schema[name]
+		result[name] = ModelConfigValue{
+			Value:       typed,
+			Source:      value.Source,
+			Type:        string(field.Type),
+			Description: field.Description,
+		}
+	}
+	return result, nil
+}
+
+// Set sets model config attributes. Attributes known to the config schema
+// are checked and coerced to the right type, and other attributes must
+// already be in the model's config, so misspelt keys are rejected without
+// changing the model.
+func (s *ModelConfigAPI) Set(ctx context.Context, modelName string, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return errors.NotValidf("empty config")
+	}
+	schema, err := modelConfigSchema()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelConfigAPI, err := s.modelConfigClient(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = modelConfigAPI.Close() }()
+	known, err := modelConfigAPI.ModelGet()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	coerced, err := coerceModelConfig(schema, values, known)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(modelConfigAPI.ModelSet(coerced))
+}
+
+// Unset returns model config attributes to their inherited values.
+func (s *ModelConfigAPI) Unset(ctx context.Context, modelName string, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.NotValidf("no keys")
+	}
+	schema, err := modelConfigSchema()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelConfigAPI, err := s.modelConfigClient(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = modelConfigAPI.Close() }()
+	known, err := modelConfigAPI.ModelGet()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := checkModelConfigKeys(schema, keys, known); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(modelConfigAPI.ModelUnset(keys...))
+}
+
+// Defaults returns the defaults new models on the cloud are created with,
+// keyed by attribute name. If cloud is empty, the controller must have a
+// single cloud, which is used.
+func (s *ModelConfigAPI) Defaults(ctx context.Context, cloud string) (map[string]ModelDefault, error) {
+	modelManagerAPI, cloud, err := s.modelManagerClient(ctx, cloud)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = modelManagerAPI.Close() }()
+
+	defaults, err := modelManagerAPI.ModelDefaults(cloud)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make(map[string]ModelDefault, len(defaults))
+	for name, values := range defaults {
+		value := ModelDefault{
+			Default:    values.Default,
+			Controller: values.Controller,
+		}
+		if len(values.Regions) > 0 {
+			value.Regions = make(map[string]interface{}, len(values.Regions))
+			for _, region := range values.Regions {
+				value.Regions[region.Name] = region.Value
+			}
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// SetDefaults sets the defaults for new models on the cloud, or on a region
+// of it if region is set. Values are checked as for Set.
+func (s *ModelConfigAPI) SetDefaults(ctx context.Context, cloud, region string, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return errors.NotValidf("empty config")
+	}
+	schema, err := modelConfigSchema()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelManagerAPI, cloud, err := s.modelManagerClient(ctx, cloud)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = modelManagerAPI.Close() }()
+
+	known, err := knownModelDefaults(modelManagerAPI, cloud)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	coerced, err := coerceModelConfig(schema, values, known)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(modelManagerAPI.SetModelDefaults(cloud, region, coerced))
+}
+
+// UnsetDefaults removes defaults for new models on the cloud, or on a region
+// of it if region is set.
+func (s *ModelConfigAPI) UnsetDefaults(ctx context.Context, cloud, region string, keys ...string) error {
+	if len(keys) == 0 {
+		return errors.NotValidf("no keys")
+	}
+	schema, err := modelConfigSchema()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelManagerAPI, cloud, err := s.modelManagerClient(ctx, cloud)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = modelManagerAPI.Close() }()
+
+	known, err := knownModelDefaults(modelManagerAPI, cloud)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := checkModelConfigKeys(schema, keys, known); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(modelManagerAPI.UnsetModelDefaults(cloud, region, keys...))
+}
+
+func (s *ModelConfigAPI) modelConfigClient(ctx context.Context, modelName string) (*modelconfig.Client, error) {
+	root, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return modelconfig.NewClient(root), nil
+}
+
+// modelManagerClient returns a model manager client along with the cloud
+// to manage defaults for, which is the controller's only cloud if none is
+// given.
+func (s *ModelConfigAPI) modelManagerClient(ctx context.Context, cloud string) (*modelmanager.Client, string, error) {
+	if cloud != "" && !names.IsValidCloud(cloud) {
+		return nil, "", errors.NotValidf("cloud name %q", cloud)
+	}
+	root, err := s.client.NewAPIRootContext(ctx)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	if cloud == "" {
+		if cloud, err = controllerCloud(root); err != nil {
+			_ = root.Close()
+			return nil, "", errors.Trace(err)
+		}
+	}
+	return modelmanager.NewClient(root), cloud, nil
+}
+
+// controllerCloud returns the name of the controller's cloud, which must be
+// its only one. The cloud facade is called directly, as its client pulls in
+// the providers.
+func controllerCloud(caller base.APICaller) (string, error) {
+	var result params.CloudsResult
+	if err := base.NewFacadeCaller(caller, "Cloud").FacadeCall("Clouds", nil, &result); err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(result.Clouds) != 1 {
+		return "", errors.NotValidf("empty cloud on a controller with %d clouds", len(result.Clouds))
+	}
+	var cloud string
+	for tagString := range result.Clouds {
+		tag, err := names.ParseCloudTag(tagString)
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		cloud = tag.Id()
+	}
+	return cloud, nil
+}
+
+func knownModelDefaults(modelManagerAPI *modelmanager.Client, cloud string) (map[string]interface{}, error) {
+	defaults, err := modelManagerAPI.ModelDefaults(cloud)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	known := make(map[string]interface{}, len(defaults))
+	for name, values := range defaults {
+		known[name] = values.Default
+	}
+	return known, nil
+}
+
+var (
+	modelConfigSchemaOnce   sync.Once
+	modelConfigSchemaFields environschema.Fields
+	modelConfigSchemaErr    error
+)
+
+// modelConfigSchema returns the model config attributes known to Juju,
+// excluding provider specific ones. It's built on first use.
+func modelConfigSchema() (environschema.Fields, error) {
+	modelConfigSchemaOnce.Do(func() {
+		modelConfigSchemaFields, modelConfigSchemaErr = config.Schema(nil)
+	})
+	return modelConfigSchemaFields, errors.Annotate(modelConfigSchemaErr, "model config schema")
+}
+
+// coerceModelConfig checks the values can be set, returning them coerced
+// to the types in the config schema. Attributes that aren't in the schema
+// must be in known, which holds the provider specific attributes.
+func coerceModelConfig(schema environschema.Fields, values, known map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(values))
+	for name := range values {
+		keys = append(keys, name)
+	}
+	if err := checkModelConfigKeys(schema, keys, known); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	coerced := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		typed, err := typedModelConfigValue(schema, name, value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		coerced[name] = typed
+	}
+	return coerced, nil
+}
+
+// checkModelConfigKeys checks that the keys can be changed and are either
+// in the config schema or in known.
+func checkModelConfigKeys(schema environschema.Fields, keys []string, known map[string]interface{}) error {
+	// Sort a copy, so that the first bad key reported doesn't depend on
+	// map order, without reordering the caller's keys.
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if reason, ok := readOnlyModelConfigKeys[name]; ok {
+			return errors.NewNotValid(nil, fmt.Sprintf("cannot change %q, %s", name, reason))
+		}
+		if _, ok := schema[name]; ok {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return errors.NotValidf("unknown model config key %q", name)
+		}
+	}
+	return nil
+}
+
+// typedModelConfigValue coerces the value to the type of the attribute in
+// the config schema. Attributes that aren't in the schema are returned as
+// is.
+func typedModelConfigValue(schema environschema.Fields, name string, value interface{}) (interface{}, error) {
+	field, ok := schema[name]
+	if !ok || value == nil {
+		return value, nil
+	}
+	checker, err := field.Checker()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	typed, err := checker.Coerce(value, []string{name})
+	if err != nil {
+		return nil, errors.NewNotValid(err, "")
+	}
+	return typed, nil
+}
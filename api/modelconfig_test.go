@@ -0,0 +1,121 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+func TestCheckModelConfigKeys(t *testing.T) {
+	schema, err := modelConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]interface{}{
+		"vpc-id": "",
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		err  string
+	}{{
+		name: "no keys",
+	}, {
+		name: "schema and provider keys",
+		keys: []string{"logging-config", "vpc-id"},
+	}, {
+		name: "unknown key",
+		keys: []string{"logging-config", "colour"},
+		err:  `unknown model config key "colour"`,
+	}, {
+		name: "read only key",
+		keys: []string{"agent-version"},
+		err:  `cannot change "agent-version", it must be set by upgrading the model`,
+	}, {
+		name: "first bad key in order",
+		keys: []string{"zone", "name", "colour"},
+		err:  `unknown model config key "colour"`,
+	}}
+	for _, test := range tests {
+		keys := append([]string(nil), test.keys...)
+		err := checkModelConfigKeys(schema, keys, known)
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s: keys reordered to %v", test.name, keys)
+		}
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.IsNotValid(err) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want a NotValid error containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestCoerceModelConfig(t *testing.T) {
+	schema, err := modelConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]interface{}{
+		"vpc-id": "",
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   map[string]interface{}
+		err    bool
+	}{{
+		name: "typed from strings",
+		values: map[string]interface{}{
+			"automatically-retry-hooks": "false",
+			"logging-config":            "<root>=DEBUG",
+		},
+		want: map[string]interface{}{
+			"automatically-retry-hooks": false,
+			"logging-config":            "<root>=DEBUG",
+		},
+	}, {
+		name: "provider keys as is",
+		values: map[string]interface{}{
+			"vpc-id": "vpc-1",
+		},
+		want: map[string]interface{}{
+			"vpc-id": "vpc-1",
+		},
+	}, {
+		name: "wrong type",
+		values: map[string]interface{}{
+			"automatically-retry-hooks": "sometimes",
+		},
+		err: true,
+	}, {
+		name: "unknown key",
+		values: map[string]interface{}{
+			"colour": "blue",
+		},
+		err: true,
+	}}
+	for _, test := range tests {
+		got, err := coerceModelConfig(schema, test.values, known)
+		if test.err {
+			if !errors.IsNotValid(err) {
+				t.Errorf("%s: got %v, want a NotValid error", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/SimonRichardson/juju-api-example/client"
 
 	"github.com/juju/errors"
+	"github.com/juju/names/v4"
 
 	"github.com/juju/juju/api/base"
 	"github.com/juju/juju/api/modelmanager"
+	"github.com/juju/juju/apiserver/params"
 )
 
 type ModelsAPI struct {
@@ -20,18 +26,189 @@ func NewModelsAPI(client *client.Client) *ModelsAPI {
 }
 
 func (s *ModelsAPI) Models() ([]base.UserModel, error) {
+	return s.ModelsContext(context.Background())
+}
+
+// ModelsContext is like Models, but abandons any in-flight API calls once
+// the given context is done.
+func (s *ModelsAPI) ModelsContext(ctx context.Context) ([]base.UserModel, error) {
 	accountDetails, err := s.client.AccountDetails()
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	root, err := s.client.NewAPIRoot()
+	root, err := s.client.NewAPIRootContext(ctx)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	modelAPI := modelmanager.NewClient(root)
-	defer modelAPI.Close()
+	defer func() { _ = modelAPI.Close() }()
+
+	models, err := modelAPI.ListModels(accountDetails.User)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return models, nil
+}
+
+// CreateModelArgs holds the options for creating a model.
+type CreateModelArgs struct {
+	// Owner is the user that owns the model. It defaults to the current
+	// user.
+	Owner string
+	// Cloud is the cloud to create the model on. It defaults to the
+	// controller's cloud.
+	Cloud string
+	// CloudRegion is the region of the cloud to create the model in.
+	CloudRegion string
+	// Credential is the name of the cloud credential to use, optionally
+	// qualified by its owner as "owner/name". It requires Cloud.
+	Credential string
+	// Config holds model config, overriding the controller's defaults.
+	Config map[string]interface{}
+}
+
+// CreateModel creates a model and records it in the client store, so that
+// it can be connected to by name straight away.
+func (s *ModelsAPI) CreateModel(ctx context.Context, name string, args CreateModelArgs) (base.ModelInfo, error) {
+	if !names.IsValidModelName(name) {
+		return base.ModelInfo{}, errors.NotValidf("model name %q", name)
+	}
+	accountDetails, err := s.client.AccountDetails()
+	if err != nil {
+		return base.ModelInfo{}, errors.Trace(err)
+	}
+	owner := args.Owner
+	if owner == "" {
+		owner = accountDetails.User
+	}
+
+	var credentialTag names.CloudCredentialTag
+	if args.Credential != "" {
+		if args.Cloud == "" {
+			return base.ModelInfo{}, errors.NotValidf("credential %q without a cloud", args.Credential)
+		}
+		credentialOwner, credentialName := accountDetails.User, args.Credential
+		if parts := strings.SplitN(args.Credential, "/", 2); len(parts) == 2 {
+			credentialOwner, credentialName = parts[0], parts[1]
+		}
+		id := args.Cloud + "/" + credentialOwner + "/" + credentialName
+		if !names.IsValidCloudCredential(id) {
+			return base.ModelInfo{}, errors.NotValidf("credential %q", args.Credential)
+		}
+		credentialTag = names.NewCloudCredentialTag(id)
+	}
+
+	root, err := s.client.NewAPIRootContext(ctx)
+	if err != nil {
+		return base.ModelInfo{}, errors.Trace(err)
+	}
+	modelAPI := modelmanager.NewClient(root)
+	defer func() { _ = modelAPI.Close() }()
+
+	info, err := modelAPI.CreateModel(name, owner, args.Cloud, args.CloudRegion, credentialTag, args.Config)
+	if err != nil {
+		return base.ModelInfo{}, errors.Trace(err)
+	}
+	if err := s.client.StoreModel(base.UserModel{
+		Name:  info.Name,
+		UUID:  info.UUID,
+		Type:  info.Type,
+		Owner: info.Owner,
+	}); err != nil {
+		return info, errors.Annotatef(err, "storing model %q", name)
+	}
+	return info, nil
+}
+
+// DestroyModelArgs holds the options for destroying a model.
+type DestroyModelArgs struct {
+	// DestroyStorage controls what happens to the model's storage. If
+	// true the storage is destroyed, if false it's released from the
+	// model. If nil, destroying a model with storage fails.
+	DestroyStorage *bool
+	// Force removes the model even if it can't be cleaned up properly.
+	Force bool
+	// MaxWait bounds how long each forced step waits for a normal
+	// removal to complete. It requires Force.
+	MaxWait time.Duration
+	// Timeout bounds how long the controller waits for the model to be
+	// removed. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// DestroyModel starts destroying a model and removes it from the client
+// store. The model is removed by the controller in the background.
+func (s *ModelsAPI) DestroyModel(ctx context.Context, modelName string, args DestroyModelArgs) error {
+	if modelName == "" {
+		return errors.NotValidf("empty model name")
+	}
+	if args.MaxWait != 0 && !args.Force {
+		return errors.NotValidf("max wait without force")
+	}
+	modelTag, err := s.modelTag(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	root, err := s.client.NewAPIRootContext(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	modelAPI := modelmanager.NewClient(root)
+	defer func() { _ = modelAPI.Close() }()
 
-	return modelAPI.ListModels(accountDetails.User)
+	var force *bool
+	var maxWait *time.Duration
+	if args.Force {
+		force = &args.Force
+		if args.MaxWait != 0 {
+			maxWait = &args.MaxWait
+		}
+	}
+	if err := modelAPI.DestroyModel(modelTag, args.DestroyStorage, force, maxWait, args.Timeout); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.client.ForgetModel(modelName))
+}
+
+// ModelInfo returns the details of a model.
+func (s *ModelsAPI) ModelInfo(ctx context.Context, modelName string) (params.ModelInfo, error) {
+	modelTag, err := s.modelTag(ctx, modelName)
+	if err != nil {
+		return params.ModelInfo{}, errors.Trace(err)
+	}
+
+	root, err := s.client.NewAPIRootContext(ctx)
+	if err != nil {
+		return params.ModelInfo{}, errors.Trace(err)
+	}
+	modelAPI := modelmanager.NewClient(root)
+	defer func() { _ = modelAPI.Close() }()
+
+	results, err := modelAPI.ModelInfo([]names.ModelTag{modelTag})
+	if err != nil {
+		return params.ModelInfo{}, errors.Trace(err)
+	}
+	if len(results) != 1 {
+		return params.ModelInfo{}, errors.Errorf("expected 1 result, got %d", len(results))
+	}
+	if results[0].Error != nil {
+		return params.ModelInfo{}, errors.Trace(results[0].Error)
+	}
+	if results[0].Result == nil {
+		return params.ModelInfo{}, errors.NotFoundf("model %q", modelName)
+	}
+	return *results[0].Result, nil
+}
+
+// modelTag returns the tag of the named model, looking it up on the
+// controller if it isn't known locally.
+func (s *ModelsAPI) modelTag(ctx context.Context, modelName string) (names.ModelTag, error) {
+	modelUUID, err := s.client.ModelUUID(ctx, modelName)
+	if err != nil {
+		return names.ModelTag{}, errors.Trace(err)
+	}
+	return names.NewModelTag(modelUUID), nil
 }
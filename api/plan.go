@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+
+	commoncharm "github.com/juju/juju/api/common/charm"
+	"github.com/juju/juju/core/constraints"
+)
+
+// DeploymentPlan describes what deploying a charm would do.
+type DeploymentPlan struct {
+	// ApplicationName is the name the application would be deployed as.
+	ApplicationName string
+	// CharmURL is the fully resolved URL of the charm.
+	CharmURL string
+	// Source is where the charm comes from: "charm-hub", "charm-store" or
+	// "local".
+	Source string
+	// Revision is the charm revision. For local charms, the controller
+	// may assign a higher revision when the charm is uploaded.
+	Revision int
+	// Channel is the channel the charm is resolved from, if any.
+	Channel string
+	// Series is the series the application would be deployed to.
+	Series string
+	// Architecture is the architecture the application would be deployed
+	// to.
+	Architecture string
+	// Constraints are the requested constraints merged over the model's.
+	Constraints constraints.Value
+}
+
+// DeployPlan does the read-only work of deploying a charm and returns the
+// resulting plan, without changing the model. The charm is resolved and its
+// series and platform selected exactly as Deploy would.
+func (s *ApplicationsAPI) DeployPlan(ctx context.Context, modelName string, charmName string, args DeployArgs) (DeploymentPlan, error) {
+	localCharm, err := prepareDeployArgs(charmName, &args)
+	if err != nil {
+		return DeploymentPlan{}, errors.Trace(err)
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return DeploymentPlan{}, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	deployCtx, err := newDeployContext(apiRoot)
+	if err != nil {
+		return DeploymentPlan{}, errors.Trace(err)
+	}
+
+	var (
+		charmURL *charm.URL
+		origin   commoncharm.Origin
+	)
+	if localCharm != nil {
+		if err := ensureWorkloadSeries(&args, args.Series, deployCtx.ModelConfig); err != nil {
+			return DeploymentPlan{}, errors.Trace(err)
+		}
+		series, err := localCharmSeries(deployCtx, localCharm, args)
+		if err != nil {
+			return DeploymentPlan{}, errors.Trace(err)
+		}
+		charmURL = localCharmURL(localCharm, series)
+		if origin, err = localCharmOrigin(deployCtx, charmURL, args.Constraints); err != nil {
+			return DeploymentPlan{}, errors.Trace(err)
+		}
+	} else {
+		if charmURL, origin, err = storeCharmOrigin(deployCtx, charmName, &args); err != nil {
+			return DeploymentPlan{}, errors.Trace(err)
+		}
+		if charmURL, origin, err = resolveStoreCharm(deployCtx, charmURL, origin, args); err != nil {
+			return DeploymentPlan{}, errors.Trace(err)
+		}
+	}
+
+	modelConstraints, err := modelConstraints(apiRoot)
+	if err != nil {
+		return DeploymentPlan{}, errors.Trace(err)
+	}
+	cons, err := mergeConstraints(modelConstraints, args.Constraints)
+	if err != nil {
+		return DeploymentPlan{}, errors.Trace(err)
+	}
+
+	revision := charmURL.Revision
+	if origin.Revision != nil {
+		revision = *origin.Revision
+	}
+	plan := DeploymentPlan{
+		ApplicationName: args.ApplicationName,
+		CharmURL:        charmURL.String(),
+		Source:          origin.Source.String(),
+		Revision:        revision,
+		Series:          origin.Series,
+		Architecture:    origin.Architecture,
+		Constraints:     cons,
+	}
+	if channel := origin.CharmChannel(); !channel.Empty() {
+		plan.Channel = channel.Normalize().String()
+	}
+	return plan, nil
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/juju/charm/v8"
+	charmresource "github.com/juju/charm/v8/resource"
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	commoncharm "github.com/juju/juju/api/common/charm"
+	resourcesclient "github.com/juju/juju/api/resources/client"
+	"github.com/juju/juju/apiserver/params"
+	corecharm "github.com/juju/juju/core/charm"
+	"github.com/juju/juju/core/model"
+)
+
+// RefreshArgs holds the options for refreshing an application's charm.
+type RefreshArgs struct {
+	// Switch switches the application to a different charm, given as for
+	// Deploy: a store charm name or URL, or the path of a local charm,
+	// which must have the same name as the current one. A path must start
+	// with "." or "local:", or be absolute. It's required
+	// to refresh a local charm.
+	Switch string
+	// Channel is the channel to refresh from. If empty, the application's
+	// current channel is kept, and if only the risk is given, the current
+	// track is kept. It can't be given for a local charm.
+	Channel charm.Channel
+	// Revision pins the revision to refresh to. If -1, the latest revision
+	// in the channel is used.
+	Revision int
+	// Force forces the refresh in cases such as LXD profiles that aren't
+	// allowed.
+	Force bool
+	// ForceSeries refreshes even if the charm doesn't support the series
+	// the application is deployed to.
+	ForceSeries bool
+	// ForceUnits refreshes units in an error state too.
+	ForceUnits bool
+	// Resources maps charm resource names to either a store revision or
+	// the path of a file to upload. Resources the new charm adds are
+	// always uploaded, using the latest store revision if not given.
+	Resources map[string]string
+}
+
+// RefreshResult describes the charm an application was refreshed from and
+// to.
+type RefreshResult struct {
+	OldCharmURL *charm.URL
+	OldOrigin   commoncharm.Origin
+	NewCharmURL *charm.URL
+	NewOrigin   commoncharm.Origin
+}
+
+// Refresh moves an application to a new revision of its charm, to another
+// channel or to a different charm altogether. Store charms are resolved in
+// the same way as for Deploy, keeping the series the application is
+// deployed to. If the application is already running the charm that
+// resolves, from the same channel, an AlreadyExists error is returned. A
+// change of channel alone is recorded, so that later refreshes follow it.
+func (s *ApplicationsAPI) Refresh(ctx context.Context, modelName, applicationName string, args RefreshArgs) (RefreshResult, error) {
+	if !names.IsValidApplication(applicationName) {
+		return RefreshResult{}, errors.NotValidf("application name %q", applicationName)
+	}
+	localCharm, err := readLocalCharm(args.Switch)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	if localCharm != nil && args.Revision != -1 {
+		return RefreshResult{}, errors.NotValidf("revision with a local charm")
+	}
+	if localCharm != nil && !args.Channel.Empty() {
+		return RefreshResult{}, errors.NotValidf("channel with a local charm")
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	deployCtx, err := newDeployContext(apiRoot)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+
+	oldURL, oldOrigin, err := deployCtx.ApplicationAPIClient.GetCharmURLOrigin(model.GenerationMaster, applicationName)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	appInfo, err := deployCtx.ApplicationAPIClient.Get(model.GenerationMaster, applicationName)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	result := RefreshResult{
+		OldCharmURL: oldURL,
+		OldOrigin:   oldOrigin,
+	}
+
+	switch {
+	case localCharm != nil:
+		result.NewCharmURL, result.NewOrigin, err = refreshLocalCharm(deployCtx, localCharm, oldURL, appInfo, args)
+	case args.Switch == "" && charm.Local.Matches(oldURL.Schema):
+		return RefreshResult{}, errors.NotValidf("refreshing local charm %q without a charm path", oldURL.Name)
+	default:
+		result.NewCharmURL, result.NewOrigin, err = refreshStoreCharm(deployCtx, applicationName, oldURL, oldOrigin, appInfo, args)
+	}
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+
+	resourceIDs, err := refreshResources(deployCtx, applicationName, oldURL, result.NewCharmURL, result.NewOrigin, args.Resources)
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	err = deployCtx.ApplicationAPIClient.SetCharm(model.GenerationMaster, application.SetCharmConfig{
+		ApplicationName: applicationName,
+		CharmID: application.CharmID{
+			URL:    result.NewCharmURL,
+			Origin: result.NewOrigin,
+		},
+		Force:       args.Force,
+		ForceSeries: args.ForceSeries,
+		ForceUnits:  args.ForceUnits,
+		ResourceIDs: resourceIDs,
+	})
+	if err != nil {
+		return RefreshResult{}, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// refreshLocalCharm uploads a charm read from disk for the application's
+// series, returning its URL and origin.
+func refreshLocalCharm(ctx deployContext, ch charm.Charm, oldURL *charm.URL, appInfo *params.ApplicationGetResults, args RefreshArgs) (*charm.URL, commoncharm.Origin, error) {
+	if name := ch.Meta().Name; name != oldURL.Name {
+		return nil, commoncharm.Origin{}, errors.NotValidf("refreshing charm %q to %q", oldURL.Name, name)
+	}
+	supportedSeries, err := corecharm.ComputedSeries(ch)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	if err := checkRefreshSeries(oldURL.Name, appInfo.Series, supportedSeries, args.ForceSeries); err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+
+	charmURL, err := ctx.LocalCharmClient.AddLocalCharm(localCharmURL(ch, appInfo.Series), ch, args.Force)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	origin, err := localCharmOrigin(ctx, charmURL, appInfo.Constraints)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	return charmURL, origin, nil
+}
+
+// refreshStoreCharm resolves the store charm to refresh to, and adds it to
+// the model, returning its URL and origin.
+func refreshStoreCharm(
+	ctx deployContext,
+	applicationName string,
+	oldURL *charm.URL, oldOrigin commoncharm.Origin,
+	appInfo *params.ApplicationGetResults,
+	args RefreshArgs,
+) (*charm.URL, commoncharm.Origin, error) {
+	refURL := oldURL.WithRevision(-1)
+	if args.Switch != "" {
+		var err error
+		if refURL, err = resolveCharmURL(args.Switch, defaultCharmSchema(ctx)); err != nil {
+			return nil, commoncharm.Origin{}, errors.Trace(err)
+		}
+		if refURL.Revision != -1 {
+			return nil, commoncharm.Origin{}, errors.NotValidf("revision in charm name %q, use Revision instead", args.Switch)
+		}
+		if err := ctx.CharmAPIClient.CheckCharmPlacement(applicationName, refURL); err != nil && !errors.IsNotSupported(err) && !args.Force {
+			return nil, commoncharm.Origin{}, errors.Trace(err)
+		}
+	}
+
+	channel := refreshChannel(oldOrigin.CharmChannel(), args.Channel)
+	refURL, origin, err := storeOrigin(ctx, refURL, args.Revision, channel, appInfo.Constraints, appInfo.Series)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	// The instance key identifies the application to the store, so it's
+	// kept across refreshes.
+	origin.InstanceKey = oldOrigin.InstanceKey
+
+	// Unlike deploy, a requested revision is kept when resolving.
+	selected, err := resolveCharm(ctx, refURL, origin, args.Switch != "", true)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+
+	supportedSeries := selected.SupportedSeries
+	if len(supportedSeries) == 0 && selected.URL.Series != "" {
+		supportedSeries = []string{selected.URL.Series}
+	}
+	if err := checkRefreshSeries(selected.URL.Name, appInfo.Series, supportedSeries, args.ForceSeries); err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+
+	charmURL, origin := resolvedCharmOrigin(selected, appInfo.Series)
+	sameChannel := origin.CharmChannel().Normalize() == oldOrigin.CharmChannel().Normalize()
+	if charmURL.String() == oldURL.String() && sameChannel {
+		return nil, commoncharm.Origin{}, errors.NewAlreadyExists(nil,
+			fmt.Sprintf("already running charm %q, revision %d", charmURL.Name, charmURL.Revision))
+	}
+
+	resultOrigin, err := ctx.CharmAPIClient.AddCharm(charmURL, origin, args.Force)
+	if err != nil {
+		return nil, commoncharm.Origin{}, errors.Trace(err)
+	}
+	return charmURL, resultOrigin, nil
+}
+
+// refreshChannel returns the channel to refresh from. A channel with only a
+// risk keeps the current track.
+func refreshChannel(current, requested charm.Channel) charm.Channel {
+	switch {
+	case requested.Empty():
+		return current
+	case requested.Track == "":
+		current.Risk = requested.Risk
+		current.Branch = requested.Branch
+		return current
+	}
+	return requested.Normalize()
+}
+
+// checkRefreshSeries checks the charm supports the series the application
+// is deployed to, unless forced.
+func checkRefreshSeries(charmName, deployedSeries string, supportedSeries []string, force bool) error {
+	if force || deployedSeries == "" || len(supportedSeries) == 0 {
+		return nil
+	}
+	if set.NewStrings(supportedSeries...).Contains(deployedSeries) {
+		return nil
+	}
+	return errors.NewNotSupported(nil, fmt.Sprintf(
+		"charm %q does not support series %q, only %v; use ForceSeries to refresh anyway",
+		charmName, deployedSeries, supportedSeries))
+}
+
+// refreshResources adds pending resources for those the new charm adds, as
+// well as any that are given, returning their IDs keyed by resource name.
+func refreshResources(
+	ctx deployContext,
+	applicationName string,
+	oldURL, newURL *charm.URL,
+	newOrigin commoncharm.Origin,
+	values map[string]string,
+) (map[string]string, error) {
+	newInfo, err := ctx.CharmAPIClient.CharmInfo(newURL.String())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	oldInfo, err := ctx.CharmAPIClient.CharmInfo(oldURL.String())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	meta := make(map[string]charmresource.Meta)
+	for name, resource := range newInfo.Meta.Resources {
+		_, existing := oldInfo.Meta.Resources[name]
+		_, given := values[name]
+		if !existing || given {
+			meta[name] = resource
+		}
+	}
+	for name := range values {
+		if _, ok := newInfo.Meta.Resources[name]; !ok {
+			return nil, errors.NotValidf("resource %q not in charm %q", name, newURL.Name)
+		}
+	}
+	return deployResources(ctx.APIRoot, applicationName, resourcesclient.CharmID{
+		URL:    newURL,
+		Origin: newOrigin,
+	}, values, meta)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+)
+
+func TestRefreshChannel(t *testing.T) {
+	current := charm.Channel{Track: "2.0", Risk: charm.Stable}
+	tests := []struct {
+		name      string
+		requested charm.Channel
+		want      charm.Channel
+	}{{
+		name: "kept",
+		want: current,
+	}, {
+		name:      "risk only keeps the track",
+		requested: charm.Channel{Risk: charm.Candidate},
+		want:      charm.Channel{Track: "2.0", Risk: charm.Candidate},
+	}, {
+		name:      "risk and branch keep the track",
+		requested: charm.Channel{Risk: charm.Edge, Branch: "fix"},
+		want:      charm.Channel{Track: "2.0", Risk: charm.Edge, Branch: "fix"},
+	}, {
+		name:      "track and risk replace the channel",
+		requested: charm.Channel{Track: "3.0", Risk: charm.Beta},
+		want:      charm.Channel{Track: "3.0", Risk: charm.Beta},
+	}, {
+		name:      "latest track is normalized",
+		requested: charm.Channel{Track: "latest", Risk: charm.Stable},
+		want:      charm.Channel{Risk: charm.Stable},
+	}}
+	for _, test := range tests {
+		if got := refreshChannel(current, test.requested); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckRefreshSeries(t *testing.T) {
+	tests := []struct {
+		name            string
+		deployedSeries  string
+		supportedSeries []string
+		force           bool
+		supported       bool
+	}{{
+		name:            "supported",
+		deployedSeries:  "focal",
+		supportedSeries: []string{"bionic", "focal"},
+		supported:       true,
+	}, {
+		name:            "unsupported",
+		deployedSeries:  "xenial",
+		supportedSeries: []string{"bionic", "focal"},
+	}, {
+		name:            "unsupported but forced",
+		deployedSeries:  "xenial",
+		supportedSeries: []string{"bionic", "focal"},
+		force:           true,
+		supported:       true,
+	}, {
+		name:           "charm without series",
+		deployedSeries: "focal",
+		supported:      true,
+	}, {
+		name:            "application without series",
+		supportedSeries: []string{"focal"},
+		supported:       true,
+	}}
+	for _, test := range tests {
+		err := checkRefreshSeries("app", test.deployedSeries, test.supportedSeries, test.force)
+		if test.supported {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.IsNotSupported(err) {
+			t.Errorf("%s: got %v, want a NotSupported error", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,563 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/api/applicationoffers"
+	"github.com/juju/juju/api/base"
+	apicharms "github.com/juju/juju/api/charms"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/crossmodel"
+)
+
+// implicitEndpoint is the endpoint Juju provides for every charm.
+var implicitEndpoint = charm.Relation{
+	Name:      "juju-info",
+	Role:      charm.RoleProvider,
+	Interface: "juju-info",
+	Scope:     charm.ScopeGlobal,
+}
+
+// Relation describes a relation between applications in the model.
+type Relation struct {
+	ID int
+	// Key identifies the relation by its endpoints, such as
+	// "wordpress:db mysql:server".
+	Key       string
+	Interface string
+	// Scope is "global", or "container" for relations to subordinates.
+	Scope     string
+	Endpoints []RelationEndpoint
+	// Status is the relation's status, such as "joined" or "suspended",
+	// and Message explains it.
+	Status  string
+	Message string
+}
+
+// RelationEndpoint describes one end of a relation.
+type RelationEndpoint struct {
+	ApplicationName string
+	Name            string
+	Role            charm.RelationRole
+	Subordinate     bool
+	// Remote is true if the application is consumed from an offer.
+	Remote bool
+}
+
+// String returns the endpoint in "application:name" form.
+func (e RelationEndpoint) String() string {
+	return e.ApplicationName + ":" + e.Name
+}
+
+// IntegrateArgs holds the options for relating two applications.
+type IntegrateArgs struct {
+	// ViaCIDRs are the subnets that traffic to the offering side of a
+	// cross-model relation comes from, so that its firewall can be
+	// opened. They're only valid when relating to an offer.
+	ViaCIDRs []string
+}
+
+// Integrate relates two applications. Each endpoint is an application in
+// the model, optionally with a relation name, as in "wordpress:db", or the
+// URL of an offer, as in "admin/prod.mysql:db", which is consumed first.
+// Offers on other controllers need the controller in the URL, and the
+// controller must be known to the client's store.
+//
+// Endpoints without a relation name are resolved from the charm metadata
+// before anything changes, so an ambiguous pair is reported along with the
+// candidates. The relation is returned once it's been added.
+func (s *ApplicationsAPI) Integrate(ctx context.Context, modelName, endpointA, endpointB string, args IntegrateArgs) (Relation, error) {
+	a, err := parseIntegrateEndpoint(endpointA)
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+	b, err := parseIntegrateEndpoint(endpointB)
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+	offer := a.offer
+	if b.offer != nil {
+		if offer != nil {
+			return Relation{}, errors.NotSupportedf("relating two offers")
+		}
+		offer = b.offer
+	}
+	for _, cidr := range args.ViaCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return Relation{}, errors.NotValidf("via CIDR %q", cidr)
+		}
+	}
+	if offer == nil && len(args.ViaCIDRs) > 0 {
+		return Relation{}, errors.NotValidf("via CIDRs without an offer")
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	status, err := fullStatus(apiRoot, nil)
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+
+	var consumeArgs *crossmodel.ConsumeApplicationArgs
+	if offer != nil {
+		if consumeArgs, err = s.offerConsumeArgs(ctx, offer); err != nil {
+			return Relation{}, errors.Trace(err)
+		}
+	}
+	charmsAPIClient := apicharms.NewClient(apiRoot)
+	candidates := make([][]candidateEndpoint, 2)
+	for i, endpoint := range []integrateEndpoint{a, b} {
+		if endpoint.offer != nil {
+			candidates[i] = remoteEndpoints(endpoint.applicationName, consumeArgs.Offer.Endpoints)
+		} else if candidates[i], err = applicationEndpoints(charmsAPIClient, status, endpoint.applicationName); err != nil {
+			return Relation{}, errors.Trace(err)
+		}
+		if candidates[i], err = selectEndpoints(candidates[i], endpoint); err != nil {
+			return Relation{}, errors.Trace(err)
+		}
+	}
+	endpoints, err := inferRelation(endpointA+" "+endpointB, candidates[0], candidates[1])
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+
+	applicationAPIClient := application.NewClient(apiRoot)
+	if consumeArgs != nil {
+		if _, err := applicationAPIClient.Consume(*consumeArgs); err != nil {
+			return Relation{}, errors.Annotatef(err, "consuming offer %q", consumeArgs.Offer.OfferURL)
+		}
+	}
+	relationEndpoints := []string{endpoints[0].String(), endpoints[1].String()}
+	_, err = applicationAPIClient.AddRelation(relationEndpoints, args.ViaCIDRs)
+	if params.IsCodeAlreadyExists(err) {
+		return Relation{}, errors.AlreadyExistsf("relation %q", strings.Join(relationEndpoints, " "))
+	}
+	if err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+
+	if status, err = fullStatus(apiRoot, nil); err != nil {
+		return Relation{}, errors.Trace(err)
+	}
+	matches := matchRelations(statusRelations(status), relationEndpoints[0], relationEndpoints[1])
+	if len(matches) != 1 {
+		return Relation{}, errors.NotFoundf("relation %q", strings.Join(relationEndpoints, " "))
+	}
+	return matches[0], nil
+}
+
+// RemoveRelationArgs holds the options for removing a relation.
+type RemoveRelationArgs struct {
+	// Force removes the relation even if there are errors.
+	Force bool
+	// MaxWait is how long a forced removal waits for each step to
+	// complete before forcing the next. It requires Force.
+	MaxWait time.Duration
+}
+
+// RemoveRelation removes the relation between the two endpoints, which are
+// applications in the model, optionally with a relation name. Consumed
+// offers are given by their name in the model. If more than one relation
+// matches, none are removed and the candidates are reported.
+func (s *ApplicationsAPI) RemoveRelation(ctx context.Context, modelName, endpointA, endpointB string, args RemoveRelationArgs) error {
+	if args.MaxWait != 0 && !args.Force {
+		return errors.NotValidf("max wait without force")
+	}
+	for _, endpoint := range []string{endpointA, endpointB} {
+		if _, _, err := splitEndpoint(endpoint); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	status, err := fullStatus(apiRoot, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	matches := matchRelations(statusRelations(status), endpointA, endpointB)
+	switch len(matches) {
+	case 0:
+		return errors.NotFoundf("relation between %q and %q", endpointA, endpointB)
+	case 1:
+	default:
+		keys := make([]string, len(matches))
+		for i, relation := range matches {
+			keys[i] = fmt.Sprintf("%q", relation.Key)
+		}
+		return errors.NewNotValid(nil, fmt.Sprintf("ambiguous relation: %q could refer to %s",
+			endpointA+" "+endpointB, strings.Join(keys, "; ")))
+	}
+	return errors.Trace(destroyRelation(apiRoot, matches[0].ID, args))
+}
+
+// RemoveRelationID removes the relation with the given ID.
+func (s *ApplicationsAPI) RemoveRelationID(ctx context.Context, modelName string, id int, args RemoveRelationArgs) error {
+	if args.MaxWait != 0 && !args.Force {
+		return errors.NotValidf("max wait without force")
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	return errors.Trace(destroyRelation(apiRoot, id, args))
+}
+
+// Relations returns the relations in the model, ordered by ID.
+func (s *ApplicationsAPI) Relations(ctx context.Context, modelName string) ([]Relation, error) {
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+	status, err := fullStatus(apiRoot, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return statusRelations(status), nil
+}
+
+func destroyRelation(apiRoot base.APICallCloser, id int, args RemoveRelationArgs) error {
+	var force *bool
+	var maxWait *time.Duration
+	if args.Force {
+		force = &args.Force
+		if args.MaxWait != 0 {
+			maxWait = &args.MaxWait
+		}
+	}
+	return errors.Trace(application.NewClient(apiRoot).DestroyRelationId(id, force, maxWait))
+}
+
+// offerConsumeArgs returns the arguments for consuming the offer, read from
+// the controller hosting it.
+func (s *ApplicationsAPI) offerConsumeArgs(ctx context.Context, offer *crossmodel.OfferURL) (*crossmodel.ConsumeApplicationArgs, error) {
+	controllerName := offer.Source
+	if controllerName == "" {
+		controllerName = s.client.ControllerName()
+	}
+	controllerRoot, err := s.client.NewControllerAPIRootContext(ctx, controllerName)
+	if err != nil {
+		return nil, errors.Annotatef(err, "connecting to controller %q", controllerName)
+	}
+	defer func() { _ = controllerRoot.Close() }()
+	details, err := applicationoffers.NewClient(controllerRoot).GetConsumeDetails(offer.AsLocal().String())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if details.Offer == nil {
+		return nil, errors.NotFoundf("offer %q", offer)
+	}
+
+	// The offer URL the controller returns doesn't include the controller,
+	// which the consuming model needs to find it again.
+	offerURL, err := crossmodel.ParseOfferURL(details.Offer.OfferURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	offerURL.Source = controllerName
+	details.Offer.OfferURL = offerURL.String()
+
+	args := &crossmodel.ConsumeApplicationArgs{
+		Offer:            *details.Offer,
+		ApplicationAlias: offer.ApplicationName,
+		Macaroon:         details.Macaroon,
+	}
+	if details.ControllerInfo != nil {
+		controllerTag, err := names.ParseControllerTag(details.ControllerInfo.ControllerTag)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		args.ControllerInfo = &crossmodel.ControllerInfo{
+			ControllerTag: controllerTag,
+			Alias:         controllerName,
+			Addrs:         details.ControllerInfo.Addrs,
+			CACert:        details.ControllerInfo.CACert,
+		}
+	}
+	return args, nil
+}
+
+// integrateEndpoint is an endpoint given to Integrate.
+type integrateEndpoint struct {
+	applicationName string
+	// relationName is empty if the relation is to be inferred.
+	relationName string
+	// offer is set if the endpoint is an offer to consume.
+	offer *crossmodel.OfferURL
+}
+
+func parseIntegrateEndpoint(endpoint string) (integrateEndpoint, error) {
+	if offer, err := crossmodel.ParseOfferURL(endpoint); err == nil {
+		// The relation name, if any, is parsed as part of the
+		// application name.
+		applicationName, relationName, err := splitEndpoint(offer.ApplicationName)
+		if err != nil {
+			return integrateEndpoint{}, errors.NotValidf("offer endpoint %q", endpoint)
+		}
+		offer.ApplicationName = applicationName
+		return integrateEndpoint{
+			applicationName: applicationName,
+			relationName:    relationName,
+			offer:           offer,
+		}, nil
+	}
+	applicationName, relationName, err := splitEndpoint(endpoint)
+	if err != nil {
+		return integrateEndpoint{}, errors.Trace(err)
+	}
+	return integrateEndpoint{
+		applicationName: applicationName,
+		relationName:    relationName,
+	}, nil
+}
+
+// splitEndpoint splits an "application[:relation]" endpoint.
+func splitEndpoint(endpoint string) (string, string, error) {
+	applicationName, relationName := endpoint, ""
+	if i := strings.Index(endpoint, ":"); i != -1 {
+		applicationName, relationName = endpoint[:i], endpoint[i+1:]
+		if relationName == "" {
+			return "", "", errors.NotValidf("endpoint %q", endpoint)
+		}
+	}
+	if !names.IsValidApplication(applicationName) {
+		return "", "", errors.NotValidf("endpoint %q", endpoint)
+	}
+	return applicationName, relationName, nil
+}
+
+// candidateEndpoint is an endpoint a relation could be made on.
+type candidateEndpoint struct {
+	applicationName string
+	relation        charm.Relation
+	// subordinate is true if the application's charm is a subordinate.
+	subordinate bool
+	// remote is true if the application is, or will be, consumed from an
+	// offer.
+	remote bool
+}
+
+// String returns the endpoint in "application:name" form.
+func (e candidateEndpoint) String() string {
+	return e.applicationName + ":" + e.relation.Name
+}
+
+// canRelateTo reports whether the endpoints can be related, following the
+// rules the controller applies.
+func (e candidateEndpoint) canRelateTo(other candidateEndpoint) bool {
+	if e.applicationName == other.applicationName ||
+		e.relation.Interface != other.relation.Interface ||
+		counterpartRole(e.relation.Role) != other.relation.Role {
+		return false
+	}
+	if e.relation.Scope != charm.ScopeContainer && other.relation.Scope != charm.ScopeContainer {
+		return true
+	}
+	// Container scoped relations need a subordinate, and can't be made
+	// across models.
+	return !e.remote && !other.remote && (e.subordinate || other.subordinate)
+}
+
+func counterpartRole(role charm.RelationRole) charm.RelationRole {
+	switch role {
+	case charm.RoleProvider:
+		return charm.RoleRequirer
+	case charm.RoleRequirer:
+		return charm.RoleProvider
+	}
+	return ""
+}
+
+// applicationEndpoints returns the endpoints of an application in the
+// model, read from its charm metadata, or of an offer the model has already
+// consumed. They're ordered by name.
+func applicationEndpoints(charmsAPIClient *apicharms.Client, status *params.FullStatus, applicationName string) ([]candidateEndpoint, error) {
+	if remote, ok := status.RemoteApplications[applicationName]; ok {
+		return remoteEndpoints(applicationName, remote.Endpoints), nil
+	}
+	app, ok := status.Applications[applicationName]
+	if !ok {
+		return nil, errors.NotFoundf("application %q", applicationName)
+	}
+	charmInfo, err := charmsAPIClient.CharmInfo(app.Charm)
+	if err != nil {
+		return nil, errors.Annotatef(err, "reading charm metadata for application %q", applicationName)
+	}
+	meta := charmInfo.Meta
+
+	relations := meta.CombinedRelations()
+	if _, ok := relations[implicitEndpoint.Name]; !ok {
+		relations[implicitEndpoint.Name] = implicitEndpoint
+	}
+	endpoints := make([]candidateEndpoint, 0, len(relations))
+	for _, relation := range relations {
+		endpoints = append(endpoints, candidateEndpoint{
+			applicationName: applicationName,
+			relation:        relation,
+			subordinate:     meta.Subordinate,
+		})
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].relation.Name < endpoints[j].relation.Name
+	})
+	return endpoints, nil
+}
+
+// remoteEndpoints returns the endpoints of an offer, consumed under the
+// given application name.
+func remoteEndpoints(applicationName string, offerEndpoints []params.RemoteEndpoint) []candidateEndpoint {
+	endpoints := make([]candidateEndpoint, 0, len(offerEndpoints))
+	for _, endpoint := range offerEndpoints {
+		endpoints = append(endpoints, candidateEndpoint{
+			applicationName: applicationName,
+			relation: charm.Relation{
+				Name:      endpoint.Name,
+				Role:      endpoint.Role,
+				Interface: endpoint.Interface,
+				Limit:     endpoint.Limit,
+				Scope:     charm.ScopeGlobal,
+			},
+			remote: true,
+		})
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].relation.Name < endpoints[j].relation.Name
+	})
+	return endpoints
+}
+
+// selectEndpoints returns the candidates for the given endpoint: the named
+// relation if there is one, or else every relation that isn't a peer
+// relation.
+func selectEndpoints(endpoints []candidateEndpoint, endpoint integrateEndpoint) ([]candidateEndpoint, error) {
+	var selected []candidateEndpoint
+	for _, candidate := range endpoints {
+		if endpoint.relationName == "" && candidate.relation.Role != charm.RolePeer {
+			selected = append(selected, candidate)
+		} else if candidate.relation.Name == endpoint.relationName {
+			return []candidateEndpoint{candidate}, nil
+		}
+	}
+	if endpoint.relationName != "" {
+		relationNames := make([]string, len(endpoints))
+		for i, candidate := range endpoints {
+			relationNames[i] = candidate.relation.Name
+		}
+		return nil, errors.NotFoundf("endpoint %q of application %q (it has %s)",
+			endpoint.relationName, endpoint.applicationName, strings.Join(relationNames, ", "))
+	}
+	return selected, nil
+}
+
+// inferRelation picks the pair of endpoints that can be related. If there's
+// more than one, pairs using the implicit juju-info endpoint are discarded,
+// and if that doesn't settle it the candidates are reported.
+func inferRelation(description string, a, b []candidateEndpoint) ([2]candidateEndpoint, error) {
+	var candidates [][2]candidateEndpoint
+	for _, endpointA := range a {
+		for _, endpointB := range b {
+			if endpointA.canRelateTo(endpointB) {
+				candidates = append(candidates, [2]candidateEndpoint{endpointA, endpointB})
+			}
+		}
+	}
+	if len(candidates) > 1 {
+		var explicit [][2]candidateEndpoint
+		for _, candidate := range candidates {
+			if !candidate[0].relation.IsImplicit() && !candidate[1].relation.IsImplicit() {
+				explicit = append(explicit, candidate)
+			}
+		}
+		if len(explicit) == 1 {
+			candidates = explicit
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return [2]candidateEndpoint{}, errors.NotFoundf("relation for %q", description)
+	case 1:
+		return candidates[0], nil
+	}
+	keys := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = fmt.Sprintf("%q", candidate[0].String()+" "+candidate[1].String())
+	}
+	sort.Strings(keys)
+	return [2]candidateEndpoint{}, errors.NewNotValid(nil, fmt.Sprintf("ambiguous relation: %q could refer to %s",
+		description, strings.Join(keys, "; ")))
+}
+
+// statusRelations returns the relations in the status, ordered by ID.
+func statusRelations(status *params.FullStatus) []Relation {
+	relations := make([]Relation, 0, len(status.Relations))
+	for _, relation := range status.Relations {
+		endpoints := make([]RelationEndpoint, 0, len(relation.Endpoints))
+		for _, endpoint := range relation.Endpoints {
+			_, remote := status.RemoteApplications[endpoint.ApplicationName]
+			endpoints = append(endpoints, RelationEndpoint{
+				ApplicationName: endpoint.ApplicationName,
+				Name:            endpoint.Name,
+				Role:            charm.RelationRole(endpoint.Role),
+				Subordinate:     endpoint.Subordinate,
+				Remote:          remote,
+			})
+		}
+		relations = append(relations, Relation{
+			ID:        relation.Id,
+			Key:       relation.Key,
+			Interface: relation.Interface,
+			Scope:     relation.Scope,
+			Endpoints: endpoints,
+			Status:    relation.Status.Status,
+			Message:   relation.Status.Info,
+		})
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].ID < relations[j].ID
+	})
+	return relations
+}
+
+// matchRelations returns the relations between the two endpoints, each of
+// which is an application, optionally with a relation name.
+func matchRelations(relations []Relation, endpointA, endpointB string) []Relation {
+	var matches []Relation
+	for _, relation := range relations {
+		if len(relation.Endpoints) != 2 {
+			// Peer relations only have one endpoint.
+			continue
+		}
+		first, second := relation.Endpoints[0], relation.Endpoints[1]
+		if (matchEndpoint(first, endpointA) && matchEndpoint(second, endpointB)) ||
+			(matchEndpoint(first, endpointB) && matchEndpoint(second, endpointA)) {
+			matches = append(matches, relation)
+		}
+	}
+	return matches
+}
+
+func matchEndpoint(endpoint RelationEndpoint, name string) bool {
+	if endpoint.ApplicationName == name {
+		return true
+	}
+	return endpoint.String() == name
+}
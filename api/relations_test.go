@@ -0,0 +1,182 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/charm/v8"
+	"github.com/juju/errors"
+)
+
+func testEndpoint(applicationName, name string, role charm.RelationRole, iface string) candidateEndpoint {
+	return candidateEndpoint{
+		applicationName: applicationName,
+		relation: charm.Relation{
+			Name:      name,
+			Role:      role,
+			Interface: iface,
+			Scope:     charm.ScopeGlobal,
+		},
+	}
+}
+
+func withImplicit(applicationName string, endpoints ...candidateEndpoint) []candidateEndpoint {
+	implicit := candidateEndpoint{
+		applicationName: applicationName,
+		relation:        implicitEndpoint,
+	}
+	return append(endpoints, implicit)
+}
+
+func TestSelectEndpoints(t *testing.T) {
+	endpoints := withImplicit("wordpress",
+		testEndpoint("wordpress", "db", charm.RoleRequirer, "mysql"),
+		testEndpoint("wordpress", "loadbalancer", charm.RolePeer, "reversenginx"),
+		testEndpoint("wordpress", "website", charm.RoleProvider, "http"),
+	)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		want     []string
+		err      string
+	}{{
+		name:     "inferred skips peers",
+		endpoint: "wordpress",
+		want:     []string{"wordpress:db", "wordpress:website", "wordpress:juju-info"},
+	}, {
+		name:     "named",
+		endpoint: "wordpress:website",
+		want:     []string{"wordpress:website"},
+	}, {
+		name:     "named peer",
+		endpoint: "wordpress:loadbalancer",
+		want:     []string{"wordpress:loadbalancer"},
+	}, {
+		name:     "unknown",
+		endpoint: "wordpress:cache",
+		err:      `endpoint "cache" of application "wordpress" (it has db, loadbalancer, website, juju-info) not found`,
+	}}
+	for _, test := range tests {
+		endpoint, err := parseIntegrateEndpoint(test.endpoint)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		selected, err := selectEndpoints(endpoints, endpoint)
+		if test.err != "" {
+			if !errors.IsNotFound(err) || err.Error() != test.err {
+				t.Errorf("%s: got %v, want a NotFound error %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		var got []string
+		for _, candidate := range selected {
+			got = append(got, candidate.String())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInferRelation(t *testing.T) {
+	wordpress := withImplicit("wordpress",
+		testEndpoint("wordpress", "db", charm.RoleRequirer, "mysql"),
+		testEndpoint("wordpress", "website", charm.RoleProvider, "http"),
+	)
+	mysql := withImplicit("mysql",
+		testEndpoint("mysql", "server", charm.RoleProvider, "mysql"),
+	)
+	proxy := withImplicit("proxy",
+		testEndpoint("proxy", "backend", charm.RoleRequirer, "http"),
+		testEndpoint("proxy", "admin", charm.RoleRequirer, "http"),
+	)
+	monitor := []candidateEndpoint{
+		testEndpoint("monitor", "info", charm.RoleRequirer, "juju-info"),
+	}
+	logging := candidateEndpoint{
+		applicationName: "logging",
+		relation: charm.Relation{
+			Name:      "logs",
+			Role:      charm.RoleRequirer,
+			Interface: "juju-info",
+			Scope:     charm.ScopeContainer,
+		},
+		subordinate: true,
+	}
+	remoteLogging := logging
+	remoteLogging.remote = true
+
+	tests := []struct {
+		name string
+		a, b []candidateEndpoint
+		want string
+		err  string
+	}{{
+		name: "single match",
+		a:    wordpress,
+		b:    mysql,
+		want: "wordpress:db mysql:server",
+	}, {
+		name: "implicit endpoint only",
+		a:    mysql,
+		b:    monitor,
+		want: "mysql:juju-info monitor:info",
+	}, {
+		name: "explicit preferred over implicit",
+		a:    wordpress[1:],
+		b: []candidateEndpoint{
+			testEndpoint("proxy", "backend", charm.RoleRequirer, "http"),
+			testEndpoint("proxy", "info", charm.RoleRequirer, "juju-info"),
+		},
+		want: "wordpress:website proxy:backend",
+	}, {
+		name: "ambiguous",
+		a:    wordpress,
+		b:    proxy,
+		err:  `ambiguous relation: "wordpress proxy" could refer to "wordpress:website proxy:admin"; "wordpress:website proxy:backend"`,
+	}, {
+		name: "no match",
+		a:    mysql[:1],
+		b:    proxy[:2],
+		err:  `relation for "wordpress proxy" not found`,
+	}, {
+		name: "container scoped to a subordinate",
+		a:    mysql,
+		b:    []candidateEndpoint{logging},
+		want: "mysql:juju-info logging:logs",
+	}, {
+		name: "container scoped across models",
+		a:    mysql,
+		b:    []candidateEndpoint{remoteLogging},
+		err:  "not found",
+	}, {
+		name: "same application",
+		a:    wordpress,
+		b: []candidateEndpoint{
+			testEndpoint("wordpress", "backend", charm.RoleRequirer, "http"),
+		},
+		err: "not found",
+	}}
+	for _, test := range tests {
+		got, err := inferRelation("wordpress proxy", test.a, test.b)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if key := got[0].String() + " " + got[1].String(); key != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, key, test.want)
+		}
+	}
+}
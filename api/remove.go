@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/api/storage"
+	"github.com/juju/juju/apiserver/params"
+)
+
+const (
+	// defaultRemoveTimeout is how long RemoveApplication waits for the
+	// applications to disappear from status, if no timeout is given.
+	defaultRemoveTimeout = 5 * time.Minute
+)
+
+// RemoveApplicationArgs holds the options for removing applications.
+type RemoveApplicationArgs struct {
+	// Force removes the applications even if there are errors.
+	Force bool
+	// NoWait doesn't wait between the forced removal steps. It requires
+	// Force.
+	NoWait bool
+	// DestroyStorage destroys the storage attached to the applications'
+	// units, rather than detaching it.
+	DestroyStorage bool
+	// DryRun reports what would be removed without removing anything.
+	DryRun bool
+	// Timeout is how long to wait for the applications to disappear from
+	// status. It defaults to 5 minutes.
+	Timeout time.Duration
+}
+
+// RemoveApplicationResult holds the outcome of removing one application.
+type RemoveApplicationResult struct {
+	ApplicationName string
+	// DestroyedUnits holds the names of the units removed along with the
+	// application.
+	DestroyedUnits []string
+	// DestroyedStorage holds the IDs of the storage instances destroyed.
+	DestroyedStorage []string
+	// DetachedStorage holds the IDs of the storage instances detached,
+	// which remain in the model.
+	DetachedStorage []string
+	// Error is set if the application couldn't be removed.
+	Error error
+}
+
+// RemoveApplication removes the named applications from the model, and
+// waits for them to disappear from status. A result is returned for each
+// application, in the order given; an error is only returned if the removal
+// couldn't be attempted at all.
+func (s *ApplicationsAPI) RemoveApplication(ctx context.Context, modelName string, args RemoveApplicationArgs, applicationNames ...string) ([]RemoveApplicationResult, error) {
+	if args.NoWait && !args.Force {
+		return nil, errors.NotValidf("no-wait without force")
+	}
+	if len(applicationNames) == 0 {
+		return nil, errors.NotValidf("empty application names")
+	}
+	for _, name := range applicationNames {
+		if !names.IsValidApplication(name) {
+			return nil, errors.NotValidf("application name %q", name)
+		}
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+
+	if args.DryRun {
+		return planRemoval(apiRoot, args, applicationNames)
+	}
+
+	var maxWait *time.Duration
+	if args.NoWait {
+		zero := time.Duration(0)
+		maxWait = &zero
+	}
+	results, err := application.NewClient(apiRoot).DestroyApplications(application.DestroyApplicationsParams{
+		Applications:   applicationNames,
+		DestroyStorage: args.DestroyStorage,
+		Force:          args.Force,
+		MaxWait:        maxWait,
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(results) != len(applicationNames) {
+		return nil, errors.Errorf("expected %d results, got %d", len(applicationNames), len(results))
+	}
+
+	removed := make([]RemoveApplicationResult, len(applicationNames))
+	pending := set.NewStrings()
+	for i, result := range results {
+		removed[i] = RemoveApplicationResult{
+			ApplicationName: applicationNames[i],
+		}
+		if result.Error != nil {
+			removed[i].Error = result.Error
+			continue
+		}
+		if info := result.Info; info != nil {
+			removed[i].DestroyedUnits = entityIds(info.DestroyedUnits)
+			removed[i].DestroyedStorage = entityIds(info.DestroyedStorage)
+			removed[i].DetachedStorage = entityIds(info.DetachedStorage)
+		}
+		pending.Add(applicationNames[i])
+	}
+	if pending.IsEmpty() {
+		return removed, nil
+	}
+
+	timeout := args.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoveTimeout
+	}
+	remaining := pending
+	err = waitForStatus(ctx, apiRoot, pending.SortedValues(), timeout, func(status *params.FullStatus) (bool, string, error) {
+		remaining = set.NewStrings()
+		for name := range status.Applications {
+			if pending.Contains(name) {
+				remaining.Add(name)
+			}
+		}
+		return remaining.IsEmpty(), fmt.Sprintf("applications still present: %s", strings.Join(remaining.SortedValues(), ", ")), nil
+	})
+	if err != nil {
+		for i := range removed {
+			if removed[i].Error == nil && remaining.Contains(removed[i].ApplicationName) {
+				removed[i].Error = err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// planRemoval reports the units and storage that would be removed along
+// with the applications, without changing the model.
+func planRemoval(apiRoot base.APICallCloser, args RemoveApplicationArgs, applicationNames []string) ([]RemoveApplicationResult, error) {
+	status, err := fullStatus(apiRoot, applicationNames)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	storageDetails, err := storage.NewClient(apiRoot).ListStorageDetails()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	results := make([]RemoveApplicationResult, len(applicationNames))
+	for i, name := range applicationNames {
+		results[i].ApplicationName = name
+
+		appStatus, ok := status.Applications[name]
+		if !ok {
+			results[i].Error = errors.NotFoundf("application %q", name)
+			continue
+		}
+		units := set.NewStrings()
+		for unitName, unit := range appStatus.Units {
+			units.Add(unitName)
+			for subordinateName := range unit.Subordinates {
+				units.Add(subordinateName)
+			}
+		}
+		results[i].DestroyedUnits = units.SortedValues()
+
+		for _, details := range storageDetails {
+			if !attachedToAny(details, units) {
+				continue
+			}
+			storageTag, err := names.ParseStorageTag(details.StorageTag)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if args.DestroyStorage {
+				results[i].DestroyedStorage = append(results[i].DestroyedStorage, storageTag.Id())
+			} else {
+				results[i].DetachedStorage = append(results[i].DetachedStorage, storageTag.Id())
+			}
+		}
+		sort.Strings(results[i].DestroyedStorage)
+		sort.Strings(results[i].DetachedStorage)
+	}
+	return results, nil
+}
+
+func attachedToAny(details params.StorageDetails, units set.Strings) bool {
+	for unitTag := range details.Attachments {
+		tag, err := names.ParseUnitTag(unitTag)
+		if err == nil && units.Contains(tag.Id()) {
+			return true
+		}
+	}
+	return false
+}
+
+func entityIds(entities []params.Entity) []string {
+	ids := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		tag, err := names.ParseTag(entity.Tag)
+		if err != nil {
+			ids = append(ids, entity.Tag)
+			continue
+		}
+		ids = append(ids, tag.Id())
+	}
+	return ids
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"strconv"
+
+	charmresource "github.com/juju/charm/v8/resource"
+	"github.com/juju/errors"
+	"gopkg.in/macaroon.v2"
+
+	"github.com/juju/juju/api/base"
+	resourcesclient "github.com/juju/juju/api/resources/client"
+	resourcecmd "github.com/juju/juju/cmd/juju/resource"
+	"github.com/juju/juju/resource"
+)
+
+// deployResources adds pending resources for the charm being deployed,
+// returning the pending resource IDs keyed by resource name. Values that
+// parse as integers are store revisions; anything else is the path of a
+// file to upload. Resources without a value use the latest store revision.
+func deployResources(
+	apiRoot base.APICallCloser,
+	applicationName string,
+	charmID resourcesclient.CharmID,
+	values map[string]string,
+	meta map[string]charmresource.Meta,
+) (map[string]string, error) {
+	if len(meta) == 0 {
+		return nil, nil
+	}
+
+	httpClient, err := apiRoot.HTTPClient()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resourceClient := resourcesclient.NewClient(
+		apiRoot.Context(),
+		base.NewFacadeCaller(apiRoot, resource.FacadeName),
+		httpClient,
+		apiRoot,
+	)
+
+	files := make(map[string]string)
+	revisions := make(map[string]int)
+	for name, value := range values {
+		if rev, err := strconv.Atoi(value); err == nil {
+			revisions[name] = rev
+		} else {
+			files[name] = value
+		}
+	}
+
+	ids, err := resourcecmd.DeployResources(resourcecmd.DeployResourcesArgs{
+		ApplicationID:  applicationName,
+		CharmID:        charmID,
+		ResourceValues: files,
+		Revisions:      revisions,
+		ResourcesMeta:  meta,
+		Client:         resourceDeployClient{Client: resourceClient},
+		Filesystem:     osFilesystem{},
+	})
+	return ids, errors.Trace(err)
+}
+
+// resourceDeployClient adapts the resources client to the interface
+// expected by resourcecmd.DeployResources.
+type resourceDeployClient struct {
+	*resourcesclient.Client
+}
+
+func (c resourceDeployClient) AddPendingResources(
+	applicationName string,
+	charmID resourcesclient.CharmID,
+	csMac *macaroon.Macaroon,
+	resources []charmresource.Resource,
+) ([]string, error) {
+	return c.Client.AddPendingResources(resourcesclient.AddPendingResourcesArgs{
+		ApplicationID:      applicationName,
+		CharmID:            charmID,
+		CharmStoreMacaroon: csMac,
+		Resources:          resources,
+	})
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/api/application"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/instance"
+	"github.com/juju/juju/core/life"
+	"github.com/juju/juju/core/model"
+)
+
+// ScaleArgs holds the options for scaling an application.
+type ScaleArgs struct {
+	// Placement holds the placement directives for new units. It is not
+	// supported on Kubernetes models.
+	Placement []*instance.Placement
+	// AttachStorage holds the IDs of existing storage to attach to a new
+	// unit. It is only valid when adding exactly one unit, and is not
+	// supported on Kubernetes models.
+	AttachStorage []string
+	// DestroyStorage destroys the storage of removed units, rather than
+	// detaching it.
+	DestroyStorage bool
+	// Force removes units even if there are errors.
+	Force bool
+}
+
+// ScaleResult describes the changes made by Scale.
+type ScaleResult struct {
+	// Previous is the number of live units before scaling.
+	Previous int
+	// Target is the requested number of units.
+	Target int
+	// AddedUnits holds the names of the units added, if any.
+	AddedUnits []string
+	// RemovedUnits holds the names of the units removed, if any.
+	RemovedUnits []string
+}
+
+// Scale adds or removes units so that the application has the target number
+// of units. The live unit count is read from status, so units that are
+// already dying aren't counted. When scaling down, the newest units are
+// removed first. On Kubernetes models, the application's scale is set
+// instead.
+func (s *ApplicationsAPI) Scale(ctx context.Context, modelName, applicationName string, target int, args ScaleArgs) (ScaleResult, error) {
+	if !names.IsValidApplication(applicationName) {
+		return ScaleResult{}, errors.NotValidf("application name %q", applicationName)
+	}
+	if target < 0 {
+		return ScaleResult{}, errors.NotValidf("negative target %d", target)
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return ScaleResult{}, errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+
+	status, err := fullStatus(apiRoot, []string{applicationName})
+	if err != nil {
+		return ScaleResult{}, errors.Trace(err)
+	}
+	appStatus, ok := status.Applications[applicationName]
+	if !ok {
+		return ScaleResult{}, errors.NotFoundf("application %q", applicationName)
+	}
+
+	applicationAPIClient := application.NewClient(apiRoot)
+
+	if status.Model.Type == model.CAAS.String() {
+		if len(args.Placement) > 0 || len(args.AttachStorage) > 0 {
+			return ScaleResult{}, errors.NotSupportedf("placement or attaching storage on a Kubernetes model")
+		}
+		if _, err := applicationAPIClient.ScaleApplication(application.ScaleApplicationParams{
+			ApplicationName: applicationName,
+			Scale:           target,
+			Force:           args.Force,
+		}); err != nil {
+			return ScaleResult{}, errors.Trace(err)
+		}
+		return ScaleResult{
+			Previous: appStatus.Scale,
+			Target:   target,
+		}, nil
+	}
+
+	units := liveUnits(appStatus)
+	result := ScaleResult{
+		Previous: len(units),
+		Target:   target,
+	}
+
+	switch {
+	case target > len(units):
+		added, err := applicationAPIClient.AddUnits(application.AddUnitsParams{
+			ApplicationName: applicationName,
+			NumUnits:        target - len(units),
+			Placement:       args.Placement,
+			AttachStorage:   args.AttachStorage,
+		})
+		if err != nil {
+			return result, errors.Trace(err)
+		}
+		result.AddedUnits = added
+
+	case target < len(units):
+		toRemove := units[target:]
+		results, err := applicationAPIClient.DestroyUnits(application.DestroyUnitsParams{
+			Units:          toRemove,
+			DestroyStorage: args.DestroyStorage,
+			Force:          args.Force,
+		})
+		if err != nil {
+			return result, errors.Trace(err)
+		}
+		var firstErr error
+		for i, destroyed := range results {
+			if destroyed.Error != nil {
+				if firstErr == nil {
+					firstErr = errors.Annotatef(destroyed.Error, "removing unit %q", toRemove[i])
+				}
+				continue
+			}
+			result.RemovedUnits = append(result.RemovedUnits, toRemove[i])
+		}
+		if firstErr != nil {
+			return result, firstErr
+		}
+	}
+	return result, nil
+}
+
+// liveUnits returns the names of the application's units that aren't dying
+// or dead, oldest first.
+func liveUnits(appStatus params.ApplicationStatus) []string {
+	var units []string
+	for name, unit := range appStatus.Units {
+		if unitLife := unit.AgentStatus.Life; unitLife == life.Dying || unitLife == life.Dead {
+			continue
+		}
+		units = append(units, name)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return unitNumber(units[i]) < unitNumber(units[j])
+	})
+	return units
+}
+
+func unitNumber(unitName string) int {
+	number, err := names.UnitNumber(unitName)
+	if err != nil {
+		return -1
+	}
+	return number
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/life"
+)
+
+func TestLiveUnits(t *testing.T) {
+	unit := func(unitLife life.Value) params.UnitStatus {
+		return params.UnitStatus{
+			AgentStatus: params.DetailedStatus{
+				Life: unitLife,
+			},
+		}
+	}
+	tests := []struct {
+		name  string
+		units map[string]params.UnitStatus
+		want  []string
+	}{{
+		name: "no units",
+	}, {
+		name: "oldest first",
+		units: map[string]params.UnitStatus{
+			"app/10": unit(life.Alive),
+			"app/2":  unit(life.Alive),
+			"app/0":  unit(""),
+		},
+		want: []string{"app/0", "app/2", "app/10"},
+	}, {
+		name: "dying and dead units skipped",
+		units: map[string]params.UnitStatus{
+			"app/0": unit(life.Dying),
+			"app/1": unit(life.Alive),
+			"app/2": unit(life.Dead),
+			"app/3": unit(life.Alive),
+		},
+		want: []string{"app/1", "app/3"},
+	}, {
+		name: "all going away",
+		units: map[string]params.UnitStatus{
+			"app/0": unit(life.Dying),
+			"app/1": unit(life.Dead),
+		},
+	}}
+	for _, test := range tests {
+		got := liveUnits(params.ApplicationStatus{
+			Units: test.units,
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
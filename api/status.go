@@ -1,13 +1,26 @@
 package api
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juju/clock"
 	"github.com/juju/errors"
 
+	"github.com/juju/juju/api/base"
 	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/model"
 
 	"github.com/SimonRichardson/juju-api-example/client"
 )
 
+const (
+	// statusPollInterval is how often status is polled while waiting for
+	// the model to reach a desired state.
+	statusPollInterval = time.Second
+)
+
 type StatusAPI struct {
 	client *client.Client
 }
@@ -19,10 +32,68 @@ func NewStatusAPI(client *client.Client) *StatusAPI {
 }
 
 func (s *StatusAPI) FullStatus(patterns []string) (*params.FullStatus, error) {
-	root, err := s.client.NewModelAPIRoot("")
+	return s.FullStatusContext(context.Background(), patterns)
+}
+
+// FullStatusContext is like FullStatus, but abandons the in-flight API call
+// once the given context is done.
+func (s *StatusAPI) FullStatusContext(ctx context.Context, patterns []string) (*params.FullStatus, error) {
+	root, err := s.client.NewModelAPIRootContext(ctx, "")
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	defer func() { _ = root.Close() }()
+	return fullStatus(root, patterns)
+}
+
+// fullStatus returns the status of the model the caller is connected to.
+// The facade is called directly, rather than through api.Connection.Client,
+// so that the call goes through a context bound connection.
+func fullStatus(caller base.APICaller, patterns []string) (*params.FullStatus, error) {
+	facade := base.NewFacadeCaller(caller, "Client")
+
+	var result params.FullStatus
+	if err := facade.FacadeCall("FullStatus", params.StatusParams{Patterns: patterns}, &result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Older servers don't fill out model type, but
+	// we know a missing type is an "iaas" model.
+	if result.Model.Type == "" {
+		result.Model.Type = model.IAAS.String()
+	}
+	return &result, nil
+}
+
+// waitForStatus polls the model status until check reports that it's done,
+// check returns an error, or the timeout expires. On timeout, the reason
+// last returned by check is included in the error.
+func waitForStatus(
+	ctx context.Context,
+	caller base.APICaller,
+	patterns []string,
+	timeout time.Duration,
+	check func(*params.FullStatus) (done bool, reason string, err error),
+) error {
+	deadline := clock.WallClock.After(timeout)
+	for {
+		status, err := fullStatus(caller, patterns)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		done, reason, err := check(status)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if done {
+			return nil
+		}
 
-	return root.Client().Status(patterns)
+		select {
+		case <-ctx.Done():
+			return client.ContextError(ctx.Err())
+		case <-deadline:
+			return errors.NewTimeout(nil, fmt.Sprintf("timed out after %v: %s", timeout, reason))
+		case <-clock.WallClock.After(statusPollInterval):
+		}
+	}
 }
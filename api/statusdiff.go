@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+// StatusChangeKind identifies the kind of change between two status
+// snapshots.
+type StatusChangeKind string
+
+// The kinds of change reported by DiffStatus.
+const (
+	ApplicationAdded         StatusChangeKind = "application-added"
+	ApplicationRemoved       StatusChangeKind = "application-removed"
+	ApplicationCharmChanged  StatusChangeKind = "application-charm-changed"
+	ApplicationStatusChanged StatusChangeKind = "application-status-changed"
+	ApplicationExposeChanged StatusChangeKind = "application-expose-changed"
+
+	UnitAdded                 StatusChangeKind = "unit-added"
+	UnitRemoved               StatusChangeKind = "unit-removed"
+	UnitWorkloadStatusChanged StatusChangeKind = "unit-workload-status-changed"
+	UnitAgentStatusChanged    StatusChangeKind = "unit-agent-status-changed"
+	UnitAddressChanged        StatusChangeKind = "unit-address-changed"
+	UnitMachineChanged        StatusChangeKind = "unit-machine-changed"
+	UnitLeaderChanged         StatusChangeKind = "unit-leader-changed"
+
+	MachineAdded                 StatusChangeKind = "machine-added"
+	MachineRemoved               StatusChangeKind = "machine-removed"
+	MachineAgentStatusChanged    StatusChangeKind = "machine-agent-status-changed"
+	MachineInstanceStatusChanged StatusChangeKind = "machine-instance-status-changed"
+	MachineAddressChanged        StatusChangeKind = "machine-address-changed"
+
+	RelationAdded         StatusChangeKind = "relation-added"
+	RelationRemoved       StatusChangeKind = "relation-removed"
+	RelationStatusChanged StatusChangeKind = "relation-status-changed"
+)
+
+// StatusChange describes a single difference between two status snapshots.
+type StatusChange struct {
+	// Kind is the kind of change.
+	Kind StatusChangeKind
+	// Entity is the application, unit or machine name, or the relation
+	// key.
+	Entity string
+	// From and To hold the value before and after the change, if the
+	// change is to a value.
+	From, To string
+}
+
+// String returns a one line summary of the change.
+func (c StatusChange) String() string {
+	if c.From == "" && c.To == "" {
+		return fmt.Sprintf("%s %s", c.Entity, c.Kind)
+	}
+	return fmt.Sprintf("%s %s: %q -> %q", c.Entity, c.Kind, c.From, c.To)
+}
+
+// DiffStatus compares two status snapshots, returning the changes from
+// previous to current. A nil previous snapshot is treated as an empty
+// model, so everything in current is reported as added. Changes are
+// ordered by applications, units, machines and then relations.
+func DiffStatus(previous, current *params.FullStatus) []StatusChange {
+	if previous == nil {
+		previous = &params.FullStatus{}
+	}
+	if current == nil {
+		current = &params.FullStatus{}
+	}
+
+	var changes []StatusChange
+	changes = append(changes, diffApplications(previous.Applications, current.Applications)...)
+	changes = append(changes, diffUnits(allUnits(previous), allUnits(current))...)
+	changes = append(changes, diffMachines(allMachines(previous.Machines), allMachines(current.Machines))...)
+	changes = append(changes, diffRelations(previous.Relations, current.Relations)...)
+	return changes
+}
+
+func diffApplications(previous, current map[string]params.ApplicationStatus) []StatusChange {
+	var changes []StatusChange
+	keys := set.NewStrings()
+	for name := range previous {
+		keys.Add(name)
+	}
+	for name := range current {
+		keys.Add(name)
+	}
+	for _, name := range keys.SortedValues() {
+		before, inBefore := previous[name]
+		after, inAfter := current[name]
+		switch {
+		case !inBefore:
+			changes = append(changes, StatusChange{Kind: ApplicationAdded, Entity: name, To: after.Charm})
+		case !inAfter:
+			changes = append(changes, StatusChange{Kind: ApplicationRemoved, Entity: name, From: before.Charm})
+		default:
+			changes = appendIfChanged(changes, ApplicationCharmChanged, name, before.Charm, after.Charm)
+			changes = appendIfChanged(changes, ApplicationStatusChanged, name, formatDetailedStatus(before.Status), formatDetailedStatus(after.Status))
+			changes = appendIfChanged(changes, ApplicationExposeChanged, name, fmt.Sprint(before.Exposed), fmt.Sprint(after.Exposed))
+		}
+	}
+	return changes
+}
+
+func diffUnits(previous, current map[string]params.UnitStatus) []StatusChange {
+	var changes []StatusChange
+	keys := set.NewStrings()
+	for name := range previous {
+		keys.Add(name)
+	}
+	for name := range current {
+		keys.Add(name)
+	}
+	for _, name := range keys.SortedValues() {
+		before, inBefore := previous[name]
+		after, inAfter := current[name]
+		switch {
+		case !inBefore:
+			changes = append(changes, StatusChange{Kind: UnitAdded, Entity: name})
+		case !inAfter:
+			changes = append(changes, StatusChange{Kind: UnitRemoved, Entity: name})
+		default:
+			changes = appendIfChanged(changes, UnitWorkloadStatusChanged, name, formatDetailedStatus(before.WorkloadStatus), formatDetailedStatus(after.WorkloadStatus))
+			changes = appendIfChanged(changes, UnitAgentStatusChanged, name, formatDetailedStatus(before.AgentStatus), formatDetailedStatus(after.AgentStatus))
+			changes = appendIfChanged(changes, UnitAddressChanged, name, unitAddress(before), unitAddress(after))
+			changes = appendIfChanged(changes, UnitMachineChanged, name, before.Machine, after.Machine)
+			changes = appendIfChanged(changes, UnitLeaderChanged, name, fmt.Sprint(before.Leader), fmt.Sprint(after.Leader))
+		}
+	}
+	return changes
+}
+
+func diffMachines(previous, current map[string]params.MachineStatus) []StatusChange {
+	var changes []StatusChange
+	keys := set.NewStrings()
+	for id := range previous {
+		keys.Add(id)
+	}
+	for id := range current {
+		keys.Add(id)
+	}
+	for _, id := range keys.SortedValues() {
+		before, inBefore := previous[id]
+		after, inAfter := current[id]
+		switch {
+		case !inBefore:
+			changes = append(changes, StatusChange{Kind: MachineAdded, Entity: id})
+		case !inAfter:
+			changes = append(changes, StatusChange{Kind: MachineRemoved, Entity: id})
+		default:
+			changes = appendIfChanged(changes, MachineAgentStatusChanged, id, formatDetailedStatus(before.AgentStatus), formatDetailedStatus(after.AgentStatus))
+			changes = appendIfChanged(changes, MachineInstanceStatusChanged, id, formatDetailedStatus(before.InstanceStatus), formatDetailedStatus(after.InstanceStatus))
+			changes = appendIfChanged(changes, MachineAddressChanged, id, machineAddresses(before), machineAddresses(after))
+		}
+	}
+	return changes
+}
+
+func diffRelations(previous, current []params.RelationStatus) []StatusChange {
+	before := relationsByKey(previous)
+	after := relationsByKey(current)
+
+	var changes []StatusChange
+	keys := set.NewStrings()
+	for key := range before {
+		keys.Add(key)
+	}
+	for key := range after {
+		keys.Add(key)
+	}
+	for _, key := range keys.SortedValues() {
+		rBefore, inBefore := before[key]
+		rAfter, inAfter := after[key]
+		switch {
+		case !inBefore:
+			changes = append(changes, StatusChange{Kind: RelationAdded, Entity: key})
+		case !inAfter:
+			changes = append(changes, StatusChange{Kind: RelationRemoved, Entity: key})
+		default:
+			changes = appendIfChanged(changes, RelationStatusChanged, key, formatDetailedStatus(rBefore.Status), formatDetailedStatus(rAfter.Status))
+		}
+	}
+	return changes
+}
+
+func appendIfChanged(changes []StatusChange, kind StatusChangeKind, entity, from, to string) []StatusChange {
+	if from == to {
+		return changes
+	}
+	return append(changes, StatusChange{Kind: kind, Entity: entity, From: from, To: to})
+}
+
+// allUnits returns every unit in the model, including subordinates, keyed
+// by name.
+func allUnits(fullStatus *params.FullStatus) map[string]params.UnitStatus {
+	units := make(map[string]params.UnitStatus)
+	for _, app := range fullStatus.Applications {
+		for name, unit := range app.Units {
+			units[name] = unit
+			for subordinateName, subordinate := range unit.Subordinates {
+				units[subordinateName] = subordinate
+			}
+		}
+	}
+	return units
+}
+
+// allMachines returns every machine in the model, including containers,
+// keyed by ID.
+func allMachines(machines map[string]params.MachineStatus) map[string]params.MachineStatus {
+	result := make(map[string]params.MachineStatus)
+	for id, machine := range machines {
+		result[id] = machine
+		for containerID, container := range allMachines(machine.Containers) {
+			result[containerID] = container
+		}
+	}
+	return result
+}
+
+func relationsByKey(relations []params.RelationStatus) map[string]params.RelationStatus {
+	result := make(map[string]params.RelationStatus, len(relations))
+	for _, relation := range relations {
+		result[relation.Key] = relation
+	}
+	return result
+}
+
+func formatDetailedStatus(status params.DetailedStatus) string {
+	if status.Info == "" {
+		return status.Status
+	}
+	return status.Status + ": " + status.Info
+}
+
+func unitAddress(unit params.UnitStatus) string {
+	if unit.PublicAddress != "" {
+		return unit.PublicAddress
+	}
+	return unit.Address
+}
+
+func machineAddresses(machine params.MachineStatus) string {
+	addresses := append([]string{machine.DNSName}, machine.IPAddresses...)
+	return strings.Join(addresses, ",")
+}
+
+// PollChanges polls the model status at the given interval until the
+// context is done, calling onChange with each snapshot that differs from
+// the previous one, along with the changes. The first snapshot is diffed
+// against an empty model. A zero interval polls every second.
+func (s *StatusAPI) PollChanges(ctx context.Context, modelName string, interval time.Duration, onChange func(*params.FullStatus, []StatusChange)) error {
+	if interval <= 0 {
+		interval = statusPollInterval
+	}
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+
+	var previous *params.FullStatus
+	for {
+		current, err := fullStatus(apiRoot, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Trace(err)
+		}
+		if changes := DiffStatus(previous, current); len(changes) > 0 {
+			onChange(current, changes)
+		}
+		previous = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.WallClock.After(interval):
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+func TestDiffStatus(t *testing.T) {
+	active := params.DetailedStatus{Status: "active"}
+	idle := params.DetailedStatus{Status: "idle"}
+	started := params.DetailedStatus{Status: "started"}
+	model := func(workload params.DetailedStatus, exposed bool) *params.FullStatus {
+		return &params.FullStatus{
+			Applications: map[string]params.ApplicationStatus{
+				"app": {
+					Charm:   "ch:amd64/focal/app-1",
+					Status:  workload,
+					Exposed: exposed,
+					Units: map[string]params.UnitStatus{
+						"app/0": {
+							WorkloadStatus: workload,
+							AgentStatus:    idle,
+							Machine:        "0",
+							Subordinates: map[string]params.UnitStatus{
+								"sub/0": {
+									WorkloadStatus: workload,
+									AgentStatus:    idle,
+								},
+							},
+						},
+					},
+				},
+			},
+			Machines: map[string]params.MachineStatus{
+				"0": {
+					AgentStatus: started,
+					DNSName:     "10.0.0.1",
+					Containers: map[string]params.MachineStatus{
+						"0/lxd/0": {
+							AgentStatus: started,
+						},
+					},
+				},
+			},
+			Relations: []params.RelationStatus{{
+				Key:    "app:juju-info sub:juju-info",
+				Status: params.DetailedStatus{Status: "joined"},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		previous *params.FullStatus
+		current  *params.FullStatus
+		want     []StatusChange
+	}{{
+		name:     "unchanged",
+		previous: model(active, false),
+		current:  model(active, false),
+	}, {
+		name:    "everything added",
+		current: model(active, false),
+		want: []StatusChange{
+			{Kind: ApplicationAdded, Entity: "app", To: "ch:amd64/focal/app-1"},
+			{Kind: UnitAdded, Entity: "app/0"},
+			{Kind: UnitAdded, Entity: "sub/0"},
+			{Kind: MachineAdded, Entity: "0"},
+			{Kind: MachineAdded, Entity: "0/lxd/0"},
+			{Kind: RelationAdded, Entity: "app:juju-info sub:juju-info"},
+		},
+	}, {
+		name:     "everything removed",
+		previous: model(active, false),
+		want: []StatusChange{
+			{Kind: ApplicationRemoved, Entity: "app", From: "ch:amd64/focal/app-1"},
+			{Kind: UnitRemoved, Entity: "app/0"},
+			{Kind: UnitRemoved, Entity: "sub/0"},
+			{Kind: MachineRemoved, Entity: "0"},
+			{Kind: MachineRemoved, Entity: "0/lxd/0"},
+			{Kind: RelationRemoved, Entity: "app:juju-info sub:juju-info"},
+		},
+	}, {
+		name:     "status and expose changed",
+		previous: model(params.DetailedStatus{Status: "waiting", Info: "installing"}, false),
+		current:  model(active, true),
+		want: []StatusChange{
+			{Kind: ApplicationStatusChanged, Entity: "app", From: "waiting: installing", To: "active"},
+			{Kind: ApplicationExposeChanged, Entity: "app", From: "false", To: "true"},
+			{Kind: UnitWorkloadStatusChanged, Entity: "app/0", From: "waiting: installing", To: "active"},
+			{Kind: UnitWorkloadStatusChanged, Entity: "sub/0", From: "waiting: installing", To: "active"},
+		},
+	}}
+	for _, test := range tests {
+		got := DiffStatus(test.previous, test.current)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffStatusFields(t *testing.T) {
+	previous := &params.FullStatus{
+		Applications: map[string]params.ApplicationStatus{
+			"app": {
+				Charm: "ch:amd64/focal/app-1",
+				Units: map[string]params.UnitStatus{
+					"app/0": {
+						Address: "10.0.0.1",
+						Machine: "0",
+					},
+				},
+			},
+		},
+		Machines: map[string]params.MachineStatus{
+			"0": {
+				DNSName:     "10.0.0.1",
+				IPAddresses: []string{"10.0.0.1"},
+			},
+		},
+		Relations: []params.RelationStatus{{
+			Key:    "app:db db:server",
+			Status: params.DetailedStatus{Status: "joining"},
+		}},
+	}
+	current := &params.FullStatus{
+		Applications: map[string]params.ApplicationStatus{
+			"app": {
+				Charm: "ch:amd64/focal/app-2",
+				Units: map[string]params.UnitStatus{
+					"app/0": {
+						Address:       "10.0.0.1",
+						PublicAddress: "203.0.113.1",
+						Machine:       "1",
+						Leader:        true,
+					},
+				},
+			},
+		},
+		Machines: map[string]params.MachineStatus{
+			"0": {
+				DNSName:        "10.0.0.1",
+				IPAddresses:    []string{"10.0.0.1", "10.0.1.1"},
+				InstanceStatus: params.DetailedStatus{Status: "running"},
+			},
+		},
+		Relations: []params.RelationStatus{{
+			Key:    "app:db db:server",
+			Status: params.DetailedStatus{Status: "joined"},
+		}},
+	}
+	want := []StatusChange{
+		{Kind: ApplicationCharmChanged, Entity: "app", From: "ch:amd64/focal/app-1", To: "ch:amd64/focal/app-2"},
+		{Kind: UnitAddressChanged, Entity: "app/0", From: "10.0.0.1", To: "203.0.113.1"},
+		{Kind: UnitMachineChanged, Entity: "app/0", From: "0", To: "1"},
+		{Kind: UnitLeaderChanged, Entity: "app/0", From: "false", To: "true"},
+		{Kind: MachineInstanceStatusChanged, Entity: "0", To: "running"},
+		{Kind: MachineAddressChanged, Entity: "0", From: "10.0.0.1,10.0.0.1", To: "10.0.0.1,10.0.0.1,10.0.1.1"},
+		{Kind: RelationStatusChanged, Entity: "app:db db:server", From: "joining", To: "joined"},
+	}
+	if got := DiffStatus(previous, current); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/names/v4"
+
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/status"
+)
+
+const (
+	// defaultWaitTimeout is how long Wait waits if no timeout is given.
+	defaultWaitTimeout = 10 * time.Minute
+)
+
+// WaitCondition reports whether an application satisfies a condition, and
+// if it doesn't, the reason why.
+type WaitCondition func(applicationName string, app params.ApplicationStatus) (done bool, reason string)
+
+// WorkloadActive is satisfied once the application has units, and the
+// workload of every unit is active.
+func WorkloadActive() WaitCondition {
+	return WorkloadStatus(status.Active)
+}
+
+// WorkloadStatus is satisfied once the application has units, and the
+// workload of every unit has the given status.
+func WorkloadStatus(want status.Status) WaitCondition {
+	return func(applicationName string, app params.ApplicationStatus) (bool, string) {
+		if len(app.Units) == 0 {
+			return false, fmt.Sprintf("application %q has no units", applicationName)
+		}
+		for _, unitName := range sortedUnits(app) {
+			if got := app.Units[unitName].WorkloadStatus.Status; got != want.String() {
+				return false, fmt.Sprintf("unit %q workload is %q, not %q", unitName, got, want)
+			}
+		}
+		return true, ""
+	}
+}
+
+// AgentIdle is satisfied once the application has units, and the agent of
+// every unit is idle.
+func AgentIdle() WaitCondition {
+	return func(applicationName string, app params.ApplicationStatus) (bool, string) {
+		if len(app.Units) == 0 {
+			return false, fmt.Sprintf("application %q has no units", applicationName)
+		}
+		for _, unitName := range sortedUnits(app) {
+			if got := app.Units[unitName].AgentStatus.Status; got != status.Idle.String() {
+				return false, fmt.Sprintf("unit %q agent is %q, not %q", unitName, got, status.Idle)
+			}
+		}
+		return true, ""
+	}
+}
+
+// UnitCount is satisfied once the application has exactly n units.
+func UnitCount(n int) WaitCondition {
+	return func(applicationName string, app params.ApplicationStatus) (bool, string) {
+		if got := len(app.Units); got != n {
+			return false, fmt.Sprintf("application %q has %d units, not %d", applicationName, got, n)
+		}
+		return true, ""
+	}
+}
+
+// WaitArgs holds the arguments for waiting on applications and units.
+type WaitArgs struct {
+	// Applications are the applications to wait on.
+	Applications []string
+	// Units are the units to wait on. Conditions on a unit's application
+	// only see the selected units.
+	Units []string
+	// Conditions must all be satisfied by every selected application.
+	Conditions []WaitCondition
+	// Timeout bounds the wait. If zero, it defaults to 10 minutes.
+	Timeout time.Duration
+}
+
+// Wait blocks until the selected applications and units satisfy all of the
+// conditions. It fails as soon as a selected unit goes into an error state,
+// and on timeout the error includes the reason it was still blocked. A
+// context deadline is reported as a timeout too, as it is by the client.
+func (s *StatusAPI) Wait(ctx context.Context, modelName string, args WaitArgs) error {
+	if len(args.Applications)+len(args.Units) == 0 {
+		return errors.NotValidf("wait without applications or units")
+	}
+	selected := make(map[string][]string)
+	for _, applicationName := range args.Applications {
+		if !names.IsValidApplication(applicationName) {
+			return errors.NotValidf("application name %q", applicationName)
+		}
+		selected[applicationName] = nil
+	}
+	for _, unitName := range args.Units {
+		applicationName, err := names.UnitApplication(unitName)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		// Waiting on the whole application takes precedence.
+		if units, ok := selected[applicationName]; !ok || units != nil {
+			selected[applicationName] = append(units, unitName)
+		}
+	}
+	timeout := args.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() { _ = apiRoot.Close() }()
+
+	patterns := append(append([]string(nil), args.Applications...), args.Units...)
+	return waitForStatus(ctx, apiRoot, patterns, timeout, func(fullStatus *params.FullStatus) (bool, string, error) {
+		applicationNames := make([]string, 0, len(selected))
+		for applicationName := range selected {
+			applicationNames = append(applicationNames, applicationName)
+		}
+		sort.Strings(applicationNames)
+
+		for _, applicationName := range applicationNames {
+			app, ok := fullStatus.Applications[applicationName]
+			if !ok {
+				return false, fmt.Sprintf("application %q not found", applicationName), nil
+			}
+			if units := selected[applicationName]; units != nil {
+				app = selectUnits(app, units)
+				if len(app.Units) != len(units) {
+					return false, fmt.Sprintf("units of application %q not found", applicationName), nil
+				}
+			}
+			if err := unitsInError(app); err != nil {
+				return false, "", errors.Trace(err)
+			}
+			for _, condition := range args.Conditions {
+				if done, reason := condition(applicationName, app); !done {
+					return false, reason, nil
+				}
+			}
+		}
+		return true, "", nil
+	})
+}
+
+// selectUnits returns a copy of the application status that holds only the
+// given units.
+func selectUnits(app params.ApplicationStatus, unitNames []string) params.ApplicationStatus {
+	units := make(map[string]params.UnitStatus, len(unitNames))
+	for _, unitName := range unitNames {
+		if unit, ok := app.Units[unitName]; ok {
+			units[unitName] = unit
+		}
+	}
+	app.Units = units
+	return app
+}
+
+// unitsInError returns an error if any of the application's units, or their
+// subordinates, are in an error state.
+func unitsInError(app params.ApplicationStatus) error {
+	for _, unitName := range sortedUnits(app) {
+		unit := app.Units[unitName]
+		if err := unitInError(unitName, unit); err != nil {
+			return errors.Trace(err)
+		}
+		for subordinateName, subordinate := range unit.Subordinates {
+			if err := unitInError(subordinateName, subordinate); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
+}
+
+func unitInError(unitName string, unit params.UnitStatus) error {
+	for _, detail := range []params.DetailedStatus{unit.WorkloadStatus, unit.AgentStatus} {
+		if detail.Status == status.Error.String() {
+			return errors.Errorf("unit %q in error state: %s", unitName, detail.Info)
+		}
+	}
+	return nil
+}
+
+func sortedUnits(app params.ApplicationStatus) []string {
+	units := make([]string, 0, len(app.Units))
+	for unitName := range app.Units {
+		units = append(units, unitName)
+	}
+	sort.Strings(units)
+	return units
+}
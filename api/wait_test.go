@@ -0,0 +1,93 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/core/status"
+)
+
+func TestWaitConditions(t *testing.T) {
+	unit := func(workload, agent status.Status) params.UnitStatus {
+		return params.UnitStatus{
+			WorkloadStatus: params.DetailedStatus{
+				Status: workload.String(),
+			},
+			AgentStatus: params.DetailedStatus{
+				Status: agent.String(),
+			},
+		}
+	}
+	settled := params.ApplicationStatus{
+		Units: map[string]params.UnitStatus{
+			"app/0": unit(status.Active, status.Idle),
+			"app/1": unit(status.Active, status.Idle),
+		},
+	}
+	busy := params.ApplicationStatus{
+		Units: map[string]params.UnitStatus{
+			"app/0": unit(status.Active, status.Idle),
+			"app/1": unit(status.Maintenance, status.Executing),
+		},
+	}
+	empty := params.ApplicationStatus{}
+
+	tests := []struct {
+		name      string
+		condition WaitCondition
+		app       params.ApplicationStatus
+		done      bool
+		reason    string
+	}{{
+		name:      "workload active",
+		condition: WorkloadActive(),
+		app:       settled,
+		done:      true,
+	}, {
+		name:      "workload not active",
+		condition: WorkloadActive(),
+		app:       busy,
+		reason:    `unit "app/1" workload is "maintenance", not "active"`,
+	}, {
+		name:      "workload active without units",
+		condition: WorkloadActive(),
+		app:       empty,
+		reason:    `application "app" has no units`,
+	}, {
+		name:      "agent idle",
+		condition: AgentIdle(),
+		app:       settled,
+		done:      true,
+	}, {
+		name:      "agent not idle",
+		condition: AgentIdle(),
+		app:       busy,
+		reason:    `unit "app/1" agent is "executing", not "idle"`,
+	}, {
+		name:      "agent idle without units",
+		condition: AgentIdle(),
+		app:       empty,
+		reason:    `application "app" has no units`,
+	}, {
+		name:      "unit count",
+		condition: UnitCount(2),
+		app:       settled,
+		done:      true,
+	}, {
+		name:      "no units wanted",
+		condition: UnitCount(0),
+		app:       empty,
+		done:      true,
+	}, {
+		name:      "unit count not reached",
+		condition: UnitCount(3),
+		app:       settled,
+		reason:    `application "app" has 2 units, not 3`,
+	}}
+	for _, test := range tests {
+		done, reason := test.condition("app", test.app)
+		if done != test.done || reason != test.reason {
+			t.Errorf("%s: got %v %q, want %v %q", test.name, done, reason, test.done, test.reason)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/errors"
+
+	jujuapi "github.com/juju/juju/api"
+	"github.com/juju/juju/api/base"
+	"github.com/juju/juju/apiserver/params"
+
+	"github.com/SimonRichardson/juju-api-example/client"
+)
+
+const (
+	// watchRetryDelay is the delay before the first attempt to reconnect
+	// a model watcher. It doubles with each failed attempt.
+	watchRetryDelay = time.Second
+
+	// watchMaxRetryDelay caps the delay between attempts to reconnect a
+	// model watcher.
+	watchMaxRetryDelay = 30 * time.Second
+)
+
+// Delta is a change to an entity in the model. Exactly one of the entity
+// fields is set, according to Kind.
+type Delta struct {
+	// Kind is the kind of entity, such as "application", "unit",
+	// "machine" or "relation".
+	Kind string
+	// ID identifies the entity within its kind.
+	ID string
+	// Removed is true if the entity has been removed, in which case the
+	// entity holds its last known state.
+	Removed bool
+
+	Application       *params.ApplicationInfo
+	Unit              *params.UnitInfo
+	Machine           *params.MachineInfo
+	Relation          *params.RelationInfo
+	Charm             *params.CharmInfo
+	RemoteApplication *params.RemoteApplicationUpdate
+	ApplicationOffer  *params.ApplicationOfferInfo
+	Annotation        *params.AnnotationInfo
+	Action            *params.ActionInfo
+	Block             *params.BlockInfo
+	Branch            *params.BranchInfo
+	Model             *params.ModelUpdate
+}
+
+// WatchEvent holds a batch of deltas from the model.
+type WatchEvent struct {
+	// Reset is true when the underlying watcher has been started or
+	// restarted. The deltas then describe the whole model, so any state
+	// built from earlier events should be discarded.
+	Reset bool
+	// Deltas holds the changes in the order the controller sent them,
+	// except that relations are moved last, so that the applications they
+	// relate are seen first.
+	Deltas []Delta
+}
+
+// ModelWatcher streams the changes made to a model.
+type ModelWatcher struct {
+	changes chan WatchEvent
+	err     error
+}
+
+// Changes returns the channel that events are delivered on. It's closed
+// when the watcher stops, after which Err reports why.
+func (w *ModelWatcher) Changes() <-chan WatchEvent {
+	return w.changes
+}
+
+// Err returns the error the watcher stopped with, once the changes channel
+// has been closed. It's nil if the watcher was stopped by its context.
+func (w *ModelWatcher) Err() error {
+	return w.err
+}
+
+// Watch opens the model's AllWatcher and streams its deltas until the
+// given context is done. If the watcher is stopped by the controller it's
+// restarted, backing off if it keeps being stopped before delivering
+// anything, and if the connection drops it's reopened with backoff. Errors
+// that aren't worth retrying stop the watcher.
+func (s *StatusAPI) Watch(ctx context.Context, modelName string) (*ModelWatcher, error) {
+	apiRoot, id, err := s.watchAll(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	w := &ModelWatcher{
+		changes: make(chan WatchEvent),
+	}
+	go func() {
+		defer close(w.changes)
+		w.err = s.watchLoop(ctx, modelName, w.changes, apiRoot, id)
+	}()
+	return w, nil
+}
+
+// watchAll starts an AllWatcher on the model, returning the connection
+// bound to the context along with the watcher ID. The connection must be
+// closed once the watcher is done with.
+func (s *StatusAPI) watchAll(ctx context.Context, modelName string) (jujuapi.Connection, string, error) {
+	apiRoot, err := s.client.NewModelAPIRootContext(ctx, modelName)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	facade := base.NewFacadeCaller(apiRoot, "Client")
+
+	var result params.AllWatcherId
+	if err := facade.FacadeCall("WatchAll", nil, &result); err != nil {
+		_ = apiRoot.Close()
+		return nil, "", errors.Trace(err)
+	}
+	return apiRoot, result.AllWatcherId, nil
+}
+
+func (s *StatusAPI) watchLoop(ctx context.Context, modelName string, out chan<- WatchEvent, apiRoot jujuapi.Connection, id string) error {
+	delay := watchRetryDelay
+	// backoff waits before the next attempt, returning false if the
+	// context is done first.
+	backoff := func() bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-clock.WallClock.After(delay):
+		}
+		if delay *= 2; delay > watchMaxRetryDelay {
+			delay = watchMaxRetryDelay
+		}
+		return true
+	}
+
+	for {
+		delivered, err := s.streamDeltas(ctx, modelName, out, apiRoot, id)
+		_ = apiRoot.Close()
+		if delivered {
+			delay = watchRetryDelay
+		}
+		restarted := false
+		for err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			switch {
+			case params.IsCodeStopped(err), params.IsCodeNotFound(err):
+				// The controller stopped the watcher, or forgot it,
+				// so start a new one. That's done straight away
+				// unless the last watcher failed without delivering
+				// anything, in which case it backs off so that a
+				// watcher that keeps failing doesn't spin.
+				if (!delivered || restarted) && !backoff() {
+					return nil
+				}
+			case client.IsRetryableError(err):
+				if !backoff() {
+					return nil
+				}
+			default:
+				return errors.Trace(err)
+			}
+			apiRoot, id, err = s.watchAll(ctx, modelName)
+			restarted = true
+		}
+	}
+}
+
+// streamDeltas delivers the deltas from a single watcher until it fails or
+// the context is done, in which case the watcher is stopped. It reports
+// whether any events were delivered.
+func (s *StatusAPI) streamDeltas(ctx context.Context, modelName string, out chan<- WatchEvent, apiRoot base.APICaller, id string) (bool, error) {
+	watcher := jujuapi.NewAllWatcher(apiRoot, &id)
+	defer func() {
+		if ctx.Err() != nil {
+			s.stopWatcher(modelName, id)
+		}
+	}()
+
+	reset := true
+	for {
+		deltas, err := watcher.Next()
+		if err != nil {
+			return !reset, errors.Trace(err)
+		}
+		event := WatchEvent{
+			Reset:  reset,
+			Deltas: newDeltas(deltas),
+		}
+		select {
+		case <-ctx.Done():
+			return true, client.ContextError(ctx.Err())
+		case out <- event:
+		}
+		reset = false
+	}
+}
+
+// stopWatcher stops the watcher on the controller. The watcher's own
+// connection is bound to a context that's already done, so a fresh one is
+// used.
+func (s *StatusAPI) stopWatcher(modelName, id string) {
+	apiRoot, err := s.client.NewModelAPIRoot(modelName)
+	if err != nil {
+		return
+	}
+	defer func() { _ = apiRoot.Close() }()
+	_ = jujuapi.NewAllWatcher(apiRoot, &id).Stop()
+}
+
+// newDeltas converts the deltas from the controller, moving relations last
+// while otherwise keeping their order.
+func newDeltas(deltas []params.Delta) []Delta {
+	result := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		result = append(result, newDelta(delta))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Relation == nil && result[j].Relation != nil
+	})
+	return result
+}
+
+func newDelta(delta params.Delta) Delta {
+	entityID := delta.Entity.EntityId()
+	result := Delta{
+		Kind:    entityID.Kind,
+		ID:      entityID.Id,
+		Removed: delta.Removed,
+	}
+	switch entity := delta.Entity.(type) {
+	case *params.ApplicationInfo:
+		result.Application = entity
+	case *params.UnitInfo:
+		result.Unit = entity
+	case *params.MachineInfo:
+		result.Machine = entity
+	case *params.RelationInfo:
+		result.Relation = entity
+	case *params.CharmInfo:
+		result.Charm = entity
+	case *params.RemoteApplicationUpdate:
+		result.RemoteApplication = entity
+	case *params.ApplicationOfferInfo:
+		result.ApplicationOffer = entity
+	case *params.AnnotationInfo:
+		result.Annotation = entity
+	case *params.ActionInfo:
+		result.Action = entity
+	case *params.BlockInfo:
+		result.Block = entity
+	case *params.BranchInfo:
+		result.Branch = entity
+	case *params.ModelUpdate:
+		result.Model = entity
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/juju/juju/apiserver/params"
+)
+
+func TestNewDeltasRelationsLast(t *testing.T) {
+	deltas := []params.Delta{{
+		Entity: &params.RelationInfo{Key: "wordpress:db mysql:server"},
+	}, {
+		Entity: &params.ApplicationInfo{Name: "wordpress"},
+	}, {
+		Removed: true,
+		Entity:  &params.RelationInfo{Key: "wordpress:cache memcached:cache"},
+	}, {
+		Entity: &params.UnitInfo{Name: "wordpress/0"},
+	}, {
+		Entity: &params.ApplicationInfo{Name: "mysql"},
+	}}
+
+	var got []string
+	for _, delta := range newDeltas(deltas) {
+		got = append(got, delta.Kind+" "+delta.ID)
+	}
+	want := []string{
+		"application wordpress",
+		"unit wordpress/0",
+		"application mysql",
+		"relation wordpress:db mysql:server",
+		"relation wordpress:cache memcached:cache",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -26,12 +26,12 @@ type apiContext struct {
 // context for user interactions when authorizing.
 // The returned API context must be closed after use.
 //
-// If ctx is nil, no command-line authorization
+// If interactive is false, no command-line authorization
 // will be supported.
 //
 // This function is provided for use by commands that cannot use
 // CommandBase. Most clients should use that instead.
-func newAPIContext(store jujuclient.CookieStore, controllerName string) (*apiContext, error) {
+func newAPIContext(store jujuclient.CookieStore, controllerName string, interactive bool) (*apiContext, error) {
 	jar0, err := store.CookieJar(controllerName)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -45,6 +45,12 @@ func newAPIContext(store jujuclient.CookieStore, controllerName string) (*apiCon
 		domain:    os.Getenv("JUJU_USER_DOMAIN"),
 	}
 
+	if !interactive {
+		return &apiContext{
+			jar: jar,
+		}, nil
+	}
+
 	filler := &form.IOFiller{
 		In:  os.Stdin,
 		Out: os.Stdout,
@@ -80,15 +86,21 @@ func (ctx *apiContext) NewBakeryClient() *httpbakery.Client {
 	return client
 }
 
-// Close closes the API context, saving any cookies to the
-// persistent cookie jar.
-func (ctx *apiContext) Close() error {
+// Flush saves any cookies to the persistent cookie jar, leaving the
+// API context open.
+func (ctx *apiContext) Flush() error {
 	if err := ctx.jar.Save(); err != nil {
 		return errors.Annotatef(err, "cannot save cookie jar")
 	}
 	return nil
 }
 
+// Close closes the API context, saving any cookies to the
+// persistent cookie jar.
+func (ctx *apiContext) Close() error {
+	return ctx.Flush()
+}
+
 const domainCookieName = "domain"
 
 // domainCookieJar implements a variant of CookieJar that
@@ -2,13 +2,18 @@ package client
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-macaroon-bakery/macaroon-bakery/v3/httpbakery"
+	"github.com/juju/clock"
 	"github.com/juju/errors"
+	"github.com/juju/loggo"
 	"github.com/juju/names/v4"
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -24,65 +29,313 @@ import (
 	"github.com/juju/juju/pki"
 )
 
+var logger = loggo.GetLogger("juju-api-example.client")
+
+// Client connects to the models on a controller. It is safe for concurrent
+// use by multiple goroutines; connections, API contexts and model refreshes
+// are shared between them.
 type Client struct {
 	store jujuclient.ClientStore
+	// storeMutex serialises the client's own writes to the store.
+	storeMutex sync.Mutex
 
+	mutex       sync.Mutex
 	apiContexts map[string]*apiContext
+	contexts    flightGroup
+	refreshes   flightGroup
+
+	pool        *connPool
+	credentials CredentialProvider
+	redirects   *RedirectPolicy
+	retry       *RetryPolicy
 
 	controllerName string
 	modelName      string
+
+	// ctx is done once the client is closed. Refreshes shared between
+	// callers run on it, rather than on any one caller's context.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closed    bool
+	closeOnce sync.Once
+}
+
+// NewClient returns a client for the controller and model selected by the
+// given options. Without options, the store's current controller and model
+// are used; a controller without a current model is accepted, in which case
+// model connections must name their model. The "current" controller and
+// model recorded in the store are only ever read, never written.
+func NewClient(opts ...Option) (*Client, error) {
+	return NewClientContext(context.Background(), opts...)
 }
 
-func NewClient() (*Client, error) {
-	store := modelcmd.QualifyingClientStore{
-		ClientStore: jujuclient.NewFileClientStore(),
+// NewClientContext is like NewClient, but returns early if the given
+// context is already done.
+func NewClientContext(ctx context.Context, opts ...Option) (*Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ContextError(err)
 	}
-	currentController, err := modelcmd.DetermineCurrentController(store)
-	if err != nil {
-		return nil, errors.Trace(err)
+
+	o := newOptions(opts)
+
+	var store jujuclient.ClientStore = jujuclient.NewFileClientStore()
+	if o.store != nil {
+		store = o.store
 	}
-	currentModel, err := store.CurrentModel(currentController)
-	if err != nil {
+	if _, ok := store.(modelcmd.QualifyingClientStore); !ok {
+		store = modelcmd.QualifyingClientStore{
+			ClientStore: store,
+		}
+	}
+
+	controllerName := o.controllerName
+	if controllerName == "" {
+		var err error
+		if controllerName, err = modelcmd.DetermineCurrentController(store); err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else if _, err := store.ControllerByName(controllerName); err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	return &Client{
+	modelName := o.modelName
+	if modelName == "" {
+		// A controller without a current model is still usable for
+		// controller connections, and for models named explicitly.
+		var err error
+		if modelName, err = store.CurrentModel(controllerName); err != nil && !errors.IsNotFound(err) {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	lifetime, cancel := context.WithCancel(context.Background())
+	c := &Client{
 		store:          store,
 		apiContexts:    make(map[string]*apiContext),
-		controllerName: currentController,
-		modelName:      currentModel,
-	}, nil
+		pool:           newConnPool(clock.WallClock, defaultIdleTimeout),
+		credentials:    o.credentials,
+		redirects:      o.redirects,
+		retry:          o.retry,
+		controllerName: controllerName,
+		modelName:      modelName,
+		ctx:            lifetime,
+		cancel:         cancel,
+		refreshes: flightGroup{
+			ctx: lifetime,
+		},
+	}
+	if o.cookieFlushInterval > 0 {
+		go c.flushLoop(clock.WallClock, o.cookieFlushInterval)
+	}
+	return c, nil
+}
+
+// Close closes all pooled API connections and API contexts held by the
+// client, saving any cookies obtained while logging in. Errors from each
+// are collected into a CloseErrors. Closing a closed client is a no-op.
+func (c *Client) Close() error {
+	var errs CloseErrors
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		errs = append(errs, c.pool.Close()...)
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.closed = true
+		for controllerName, ctx := range c.apiContexts {
+			if err := ctx.Close(); err != nil {
+				errs = append(errs, errors.Annotatef(err, "closing API context for %q", controllerName))
+			}
+			delete(c.apiContexts, controllerName)
+		}
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// flushLoop periodically saves the cookies of every API context, so that
+// long-lived processes don't lose them if they exit without closing.
+func (c *Client) flushLoop(clock clock.Clock, interval time.Duration) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-clock.After(interval):
+			c.mutex.Lock()
+			for controllerName, ctx := range c.apiContexts {
+				if err := ctx.Flush(); err != nil {
+					logger.Warningf("flushing cookies for %q: %v", controllerName, err)
+				}
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// CloseErrors holds the errors encountered while closing a Client.
+type CloseErrors []error
+
+// Error is part of the error interface.
+func (e CloseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
 }
 
 func (c *Client) AccountDetails() (*jujuclient.AccountDetails, error) {
 	return c.store.AccountDetails(c.controllerName)
 }
 
+// ControllerName returns the name of the controller the client connects
+// to.
+func (c *Client) ControllerName() string {
+	return c.controllerName
+}
+
 func (c *Client) NewAPIRoot() (api.Connection, error) {
-	return c.newAPIRoot("")
+	return c.NewAPIRootContext(context.Background())
+}
+
+// NewAPIRootContext returns a controller connection bound to the given
+// context. Dialing and any facade calls made through the returned
+// connection are abandoned once the context is done.
+//
+// The connection is shared with other callers, and is kept open for as long
+// as it's referenced. Closing it releases the reference, so it must be
+// closed once it's no longer needed.
+func (c *Client) NewAPIRootContext(ctx context.Context) (api.Connection, error) {
+	conn, err := c.newAPIRoot(ctx, "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return withContext(ctx, conn), nil
+}
+
+// NewControllerAPIRootContext returns a connection to the named controller,
+// which must be known to the store, bound to the given context. It's used to
+// reach other controllers, such as those hosting offers consumed by the
+// client's models. An empty name is the client's own controller.
+func (c *Client) NewControllerAPIRootContext(ctx context.Context, controllerName string) (api.Connection, error) {
+	if controllerName == "" || controllerName == c.controllerName {
+		return c.NewAPIRootContext(ctx)
+	}
+	if _, err := c.store.ControllerByName(controllerName); err != nil {
+		return nil, errors.Trace(err)
+	}
+	key := connKey{
+		controllerName: controllerName,
+	}
+	conn, err := c.pool.get(ctx, key, func(ctx context.Context) (api.Connection, error) {
+		return c.dial(ctx, controllerName, "")
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return withContext(ctx, conn), nil
 }
 
 func (c *Client) NewModelAPIRoot(modelName string) (api.Connection, error) {
-	if modelName == "" {
-		modelName = c.modelName
+	return c.NewModelAPIRootContext(context.Background(), modelName)
+}
+
+// NewModelAPIRootContext returns a model connection bound to the given
+// context. Dialing and any facade calls made through the returned
+// connection are abandoned once the context is done. As for
+// NewAPIRootContext, it must be closed once it's no longer needed.
+//
+// An empty model name selects the client's model, which is a NotFound
+// error if the client was created without one.
+func (c *Client) NewModelAPIRootContext(ctx context.Context, modelName string) (api.Connection, error) {
+	modelName, err := c.modelNameOrDefault(modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := c.ModelUUID(ctx, modelName); err != nil {
+		return nil, errors.Trace(err)
 	}
+	conn, err := c.newAPIRoot(ctx, modelName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return withContext(ctx, conn), nil
+}
 
-	_, err := c.store.ModelByName(c.controllerName, modelName)
+// ModelUUID returns the UUID of the named model, or of the client's model
+// if the name is empty. It's read from the store, without connecting to the
+// model; a model that isn't known locally is looked up on the controller,
+// and the models found there are cached in the store.
+func (c *Client) ModelUUID(ctx context.Context, modelName string) (string, error) {
+	modelName, err := c.modelNameOrDefault(modelName)
 	if err != nil {
-		if !errors.IsNotFound(err) {
-			return nil, errors.Trace(err)
-		}
+		return "", errors.Trace(err)
+	}
+	modelDetails, err := c.store.ModelByName(c.controllerName, modelName)
+	if errors.IsNotFound(err) {
 		// The model isn't known locally, so query the models
 		// available in the controller, and cache them locally.
-		if err := c.refreshModels(); err != nil {
-			return nil, errors.Annotate(err, "refreshing models")
+		if err := c.refreshModels(ctx); err != nil {
+			return "", errors.Annotate(err, "refreshing models")
 		}
+		modelDetails, err = c.store.ModelByName(c.controllerName, modelName)
 	}
-	return c.newAPIRoot(modelName)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return modelDetails.ModelUUID, nil
 }
 
-func (c *Client) newAPIRoot(modelName string) (api.Connection, error) {
-	accountDetails, err := c.store.AccountDetails(c.controllerName)
+// modelNameOrDefault returns the given model name, or the client's model if
+// it's empty.
+func (c *Client) modelNameOrDefault(modelName string) (string, error) {
+	if modelName != "" {
+		return modelName, nil
+	}
+	if c.modelName == "" {
+		return "", errors.NotFoundf("current model for controller %q", c.controllerName)
+	}
+	return c.modelName, nil
+}
+
+// newAPIRoot returns a pooled connection to the given model, dialing a new
+// one if there is no healthy connection already cached.
+func (c *Client) newAPIRoot(ctx context.Context, modelName string) (api.Connection, error) {
+	var modelUUID string
+	if modelName != "" {
+		modelDetails, err := c.store.ModelByName(c.controllerName, modelName)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		modelUUID = modelDetails.ModelUUID
+	}
+
+	key := connKey{
+		controllerName: c.controllerName,
+		modelUUID:      modelUUID,
+	}
+	return c.pool.get(ctx, key, func(ctx context.Context) (api.Connection, error) {
+		return c.dial(ctx, c.controllerName, modelName)
+	})
+}
+
+// dial dials a new connection to the model on the controller, retrying
+// according to the client's retry policy.
+func (c *Client) dial(ctx context.Context, controllerName, modelName string) (api.Connection, error) {
+	if c.retry == nil {
+		return c.dialAPIRoot(ctx, controllerName, modelName)
+	}
+	return c.retry.call(ctx, func() (api.Connection, error) {
+		return c.dialAPIRoot(ctx, controllerName, modelName)
+	})
+}
+
+func (c *Client) dialAPIRoot(ctx context.Context, controllerName, modelName string) (api.Connection, error) {
+	accountDetails, err := c.store.AccountDetails(controllerName)
 	if err != nil && !errors.IsNotFound(err) {
 		return nil, errors.Trace(err)
 	}
@@ -108,8 +361,16 @@ func (c *Client) newAPIRoot(modelName string) (api.Connection, error) {
 		}
 	}
 
+	if c.credentials != nil {
+		creds, err := c.credentials.Credentials(controllerName, accountDetails.User)
+		if err != nil {
+			return nil, errors.Annotate(err, "getting credentials")
+		}
+		accountDetails = mergeCredentials(accountDetails, creds)
+	}
+
 	param, err := c.newAPIConnectionParams(
-		c.store, c.controllerName, modelName, accountDetails,
+		ctx, c.store, controllerName, modelName, accountDetails,
 	)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -117,10 +378,15 @@ func (c *Client) newAPIRoot(modelName string) (api.Connection, error) {
 
 	conn, err := juju.NewAPIConnection(param)
 	if modelName != "" && params.ErrCode(err) == params.CodeModelNotFound {
-		return nil, c.missingModelError(c.store, c.controllerName, modelName)
+		return nil, c.missingModelError(c.store, controllerName, modelName)
 	}
 	if redirectErr, ok := errors.Cause(err).(*api.RedirectError); ok {
-		return nil, c.newModelMigratedError(c.store, modelName, redirectErr)
+		// Redirects are only followed from the client's own controller,
+		// so a chain of redirects can't loop.
+		if c.redirects == nil || controllerName != c.controllerName {
+			return nil, c.newModelMigratedError(c.store, modelName, redirectErr)
+		}
+		return c.followRedirect(ctx, modelName, redirectErr)
 	}
 	if juju.IsNoAddressesError(err) {
 		return nil, errors.New("no controller API addresses; is bootstrap still in progress?")
@@ -128,8 +394,19 @@ func (c *Client) newAPIRoot(modelName string) (api.Connection, error) {
 	return conn, errors.Trace(err)
 }
 
-func (c *Client) refreshModels() error {
-	root, err := c.NewAPIRoot()
+// refreshModels queries the models available on the controller and caches
+// them in the store. Concurrent refreshes share a single query, which is
+// abandoned once every caller has given up waiting on it, or the client is
+// closed.
+func (c *Client) refreshModels(ctx context.Context) error {
+	_, err := c.refreshes.do(ctx, c.controllerName, func(ctx context.Context) (interface{}, error) {
+		return nil, c.doRefreshModels(ctx)
+	})
+	return errors.Trace(err)
+}
+
+func (c *Client) doRefreshModels(ctx context.Context) error {
+	root, err := c.NewAPIRootContext(ctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -155,22 +432,68 @@ func (c *Client) refreshModels() error {
 func (c *Client) storeModels(controllerName string, models []base.UserModel) error {
 	modelsToStore := make(map[string]jujuclient.ModelDetails, len(models))
 	for _, model := range models {
-		modelDetails := jujuclient.ModelDetails{ModelUUID: model.UUID, ModelType: model.Type}
-		owner := names.NewUserTag(model.Owner)
-		modelName := jujuclient.JoinOwnerModelName(owner, model.Name)
+		modelName, modelDetails := storedModel(model)
 		modelsToStore[modelName] = modelDetails
 	}
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
 	if err := c.store.SetModels(controllerName, modelsToStore); err != nil {
 		return errors.Trace(err)
 	}
 	return nil
 }
 
+// StoreModel records a model in the client store, so that it can be
+// connected to by name straight away, without refreshing the models.
+func (c *Client) StoreModel(model base.UserModel) error {
+	modelName, modelDetails := storedModel(model)
+
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	return errors.Trace(c.store.UpdateModel(c.controllerName, modelName, modelDetails))
+}
+
+// ForgetModel removes a model from the client store, and evicts its pooled
+// connection. A model that isn't in the store is ignored.
+func (c *Client) ForgetModel(modelName string) error {
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	modelDetails, err := c.store.ModelByName(c.controllerName, modelName)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	c.pool.evict(connKey{
+		controllerName: c.controllerName,
+		modelUUID:      modelDetails.ModelUUID,
+	})
+
+	err = c.store.RemoveModel(c.controllerName, modelName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// storedModel returns the qualified name and details a model is stored
+// under.
+func storedModel(model base.UserModel) (string, jujuclient.ModelDetails) {
+	owner := names.NewUserTag(model.Owner)
+	modelName := jujuclient.JoinOwnerModelName(owner, model.Name)
+	return modelName, jujuclient.ModelDetails{ModelUUID: model.UUID, ModelType: model.Type}
+}
+
 // NewAPIConnectionParams returns a juju.NewAPIConnectionParams with the
 // given arguments such that a call to juju.NewAPIConnection with the
 // result behaves the same as a call to CommandBase.NewAPIRoot with
 // the same arguments.
 func (c *Client) newAPIConnectionParams(
+	ctx context.Context,
 	store jujuclient.ClientStore,
 	controllerName, modelName string,
 	accountDetails *jujuclient.AccountDetails,
@@ -180,14 +503,29 @@ func (c *Client) newAPIConnectionParams(
 		return juju.NewAPIConnectionParams{}, errors.Trace(err)
 	}
 
-	return newAPIConnectionParams(
+	open := api.Open
+	if c.retry != nil {
+		open = c.retry.failover(open)
+	}
+
+	param, err := newAPIConnectionParams(
 		store, controllerName, modelName,
 		accountDetails,
 		false,
+		isInteractive(c.credentials),
 		bakeryClient,
-		api.Open,
-		getPassword,
+		openWithContext(ctx, open),
+		passwordGetter(c.credentials, accountDetails.Password),
 	)
+	if err != nil {
+		return juju.NewAPIConnectionParams{}, errors.Trace(err)
+	}
+	if c.retry != nil {
+		// The retry policy replaces the dialer's own retries.
+		param.DialOpts.RetryDelay = 0
+	}
+	param.DialOpts = dialOptsWithContext(ctx, param.DialOpts)
+	return param, nil
 }
 
 // BakeryClient returns a macaroon bakery client that
@@ -201,21 +539,39 @@ func (c *Client) bakeryClient(store jujuclient.CookieStore, controllerName strin
 }
 
 // getAPIContext returns an apiContext for the given controller.
-// It will return the same context if called twice for the same controller.
-// The context will be closed when closeAPIContexts is called.
+// It will return the same context if called twice for the same controller,
+// including when called concurrently.
+// The context will be closed when Close is called.
 func (c *Client) getAPIContext(store jujuclient.CookieStore, controllerName string) (*apiContext, error) {
-	if ctx := c.apiContexts[controllerName]; ctx != nil {
-		return ctx, nil
-	}
 	if controllerName == "" {
 		return nil, errors.New("cannot get API context from empty controller name")
 	}
-	ctx, err := newAPIContext(c.store, c.controllerName)
+	ctx, err := c.contexts.do(context.Background(), controllerName, func(context.Context) (interface{}, error) {
+		c.mutex.Lock()
+		ctx := c.apiContexts[controllerName]
+		c.mutex.Unlock()
+		if ctx != nil {
+			return ctx, nil
+		}
+
+		ctx, err := newAPIContext(store, controllerName, isInteractive(c.credentials))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		if c.closed {
+			return nil, errors.New("client closed")
+		}
+		c.apiContexts[controllerName] = ctx
+		return ctx, nil
+	})
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	c.apiContexts[controllerName] = ctx
-	return ctx, nil
+	return ctx.(*apiContext), nil
 }
 
 func (c *Client) missingModelError(store jujuclient.ClientStore, controllerName, modelName string) error {
@@ -276,6 +632,7 @@ func newAPIConnectionParams(
 	modelName string,
 	accountDetails *jujuclient.AccountDetails,
 	embedded bool,
+	interactive bool,
 	bakery *httpbakery.Client,
 	apiOpen api.OpenFunc,
 	getPassword func(string) (string, error),
@@ -298,7 +655,10 @@ func newAPIConnectionParams(
 	if accountDetails != nil && !embedded {
 		bakery.InteractionMethods = []httpbakery.Interactor{
 			authentication.NewInteractor(accountDetails.User, getPassword),
-			httpbakery.WebBrowserInteractor{},
+		}
+		// Only fall back to a browser login when the user can respond.
+		if interactive {
+			bakery.InteractionMethods = append(bakery.InteractionMethods, httpbakery.WebBrowserInteractor{})
 		}
 	}
 
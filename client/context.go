@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+)
+
+// ContextError converts a context error into the error returned to callers.
+// An exceeded deadline is reported as a timeout, which can be checked for
+// using errors.IsTimeout. It's exported so that packages built on the
+// client report context errors in the same way.
+func ContextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return errors.NewTimeout(err, "deadline exceeded")
+	}
+	return errors.Trace(err)
+}
+
+// dialOptsWithContext bounds the dial timeouts by the context deadline, if
+// there is one.
+func dialOptsWithContext(ctx context.Context, opts api.DialOpts) api.DialOpts {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return opts
+	}
+	remaining := time.Until(deadline)
+	if opts.Timeout == 0 || remaining < opts.Timeout {
+		opts.Timeout = remaining
+	}
+	if opts.DialTimeout == 0 || remaining < opts.DialTimeout {
+		opts.DialTimeout = remaining
+	}
+	return opts
+}
+
+// openWithContext wraps the given open function so that it returns as soon
+// as the context is done. A connection that completes after the context is
+// done is closed.
+func openWithContext(ctx context.Context, open api.OpenFunc) api.OpenFunc {
+	return func(info *api.Info, opts api.DialOpts) (api.Connection, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, ContextError(err)
+		}
+
+		type result struct {
+			conn api.Connection
+			err  error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			conn, err := open(info, opts)
+			ch <- result{conn: conn, err: err}
+		}()
+
+		select {
+		case r := <-ch:
+			return r.conn, r.err
+		case <-ctx.Done():
+			go func() {
+				if r := <-ch; r.conn != nil {
+					_ = r.conn.Close()
+				}
+			}()
+			return nil, ContextError(ctx.Err())
+		}
+	}
+}
+
+// withContext binds the connection to the given context. A context that
+// can never be done is returned as is.
+func withContext(ctx context.Context, conn api.Connection) api.Connection {
+	if ctx.Done() == nil {
+		return conn
+	}
+	return contextConnection{
+		Connection: conn,
+		ctx:        ctx,
+	}
+}
+
+// contextConnection is an api.Connection bound to a context. Facade calls
+// made through it return once the context is done, without waiting for the
+// server to respond.
+type contextConnection struct {
+	api.Connection
+	ctx context.Context
+}
+
+// APICall is part of the base.APICaller interface.
+func (c contextConnection) APICall(objType string, version int, id, request string, args, response interface{}) error {
+	if err := c.ctx.Err(); err != nil {
+		return ContextError(err)
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Connection.APICall(objType, version, id, request, args, response)
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-c.ctx.Done():
+		return ContextError(c.ctx.Err())
+	}
+}
+
+// Context is part of the base.APICaller interface.
+func (c contextConnection) Context() context.Context {
+	return c.ctx
+}
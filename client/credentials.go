@@ -0,0 +1,145 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/macaroon.v2"
+
+	"github.com/juju/juju/jujuclient"
+)
+
+// Credentials holds the credentials used to log in to a controller. Empty
+// fields fall back to the account details recorded in the client store.
+type Credentials struct {
+	User      string
+	Password  string
+	Macaroons []macaroon.Slice
+}
+
+// CredentialProvider supplies the credentials used to log in to a
+// controller. The user is the one recorded in the client store for the
+// controller, and may be empty.
+type CredentialProvider interface {
+	Credentials(controllerName, user string) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function to a CredentialProvider.
+type CredentialProviderFunc func(controllerName, user string) (Credentials, error)
+
+// Credentials is part of the CredentialProvider interface.
+func (f CredentialProviderFunc) Credentials(controllerName, user string) (Credentials, error) {
+	return f(controllerName, user)
+}
+
+// StaticCredentials returns a provider that always logs in with the given
+// username and password.
+func StaticCredentials(user, password string) CredentialProvider {
+	return CredentialProviderFunc(func(string, string) (Credentials, error) {
+		return Credentials{
+			User:     user,
+			Password: password,
+		}, nil
+	})
+}
+
+// PasswordFromFile returns a provider that reads the password from the
+// given file each time a connection is made, so the file can be rotated
+// without restarting the process. If user is empty, the stored user is
+// used.
+func PasswordFromFile(user, path string) CredentialProvider {
+	return CredentialProviderFunc(func(string, string) (Credentials, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Credentials{}, errors.Annotatef(err, "reading password file")
+		}
+		return Credentials{
+			User:     user,
+			Password: strings.TrimRight(string(data), "\r\n"),
+		}, nil
+	})
+}
+
+// PasswordFromEnv returns a provider that reads the password from the given
+// environment variable. If user is empty, the stored user is used.
+func PasswordFromEnv(user, key string) CredentialProvider {
+	return CredentialProviderFunc(func(string, string) (Credentials, error) {
+		password, ok := os.LookupEnv(key)
+		if !ok {
+			return Credentials{}, errors.NotFoundf("environment variable %q", key)
+		}
+		return Credentials{
+			User:     user,
+			Password: password,
+		}, nil
+	})
+}
+
+// MacaroonCredentials returns a provider that logs in with macaroons that
+// have already been obtained and discharged.
+func MacaroonCredentials(user string, macaroons []macaroon.Slice) CredentialProvider {
+	return CredentialProviderFunc(func(string, string) (Credentials, error) {
+		return Credentials{
+			User:      user,
+			Macaroons: macaroons,
+		}, nil
+	})
+}
+
+// InteractiveCredentials returns a provider that uses the stored account
+// details, and prompts on the terminal for a password or browser login
+// when the controller asks for one. It is the only provider that reads
+// from stdin.
+func InteractiveCredentials() CredentialProvider {
+	return interactiveCredentials{}
+}
+
+type interactiveCredentials struct{}
+
+// Credentials is part of the CredentialProvider interface.
+func (interactiveCredentials) Credentials(string, string) (Credentials, error) {
+	return Credentials{}, nil
+}
+
+// isInteractive reports whether the provider may prompt the user.
+func isInteractive(provider CredentialProvider) bool {
+	_, ok := provider.(interactiveCredentials)
+	return ok
+}
+
+// mergeCredentials overlays the provided credentials on the stored account
+// details. Stored secrets are dropped if the provider selects a different
+// user.
+func mergeCredentials(details *jujuclient.AccountDetails, creds Credentials) *jujuclient.AccountDetails {
+	merged := *details
+	if creds.User != "" && creds.User != merged.User {
+		merged = jujuclient.AccountDetails{
+			User: creds.User,
+		}
+	}
+	if creds.Password != "" {
+		merged.Password = creds.Password
+	}
+	if len(creds.Macaroons) > 0 {
+		merged.Macaroons = creds.Macaroons
+	}
+	return &merged
+}
+
+// passwordGetter returns the function used to answer a controller's request
+// for a password. Only the interactive provider ever prompts for one.
+func passwordGetter(provider CredentialProvider, password string) func(string) (string, error) {
+	if password != "" {
+		return func(string) (string, error) {
+			return password, nil
+		}
+	}
+	if isInteractive(provider) {
+		return getPassword
+	}
+	return func(username string) (string, error) {
+		return "", errors.Errorf("password required for %q, but no credential provider supplied one", username)
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup de-duplicates concurrent calls for the same key, so that only
+// one of them does the work and the rest share its result.
+type flightGroup struct {
+	// ctx, if set, is the context that calls run on. Otherwise they run
+	// on context.Background.
+	ctx context.Context
+
+	mutex sync.Mutex
+	calls map[interface{}]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	value   interface{}
+	err     error
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// do calls fn, unless a call for the same key is already in flight, in
+// which case it shares that call's result. The call runs in its own
+// goroutine, and each caller waits for it only until its own context is
+// done, so one caller giving up doesn't fail the others. The context passed
+// to fn is cancelled once every caller has given up, so that a call nobody
+// is waiting for is abandoned; a later caller starts a new call.
+func (g *flightGroup) do(ctx context.Context, key interface{}, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[interface{}]*flight)
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		parent := g.ctx
+		if parent == nil {
+			parent = context.Background()
+		}
+		callCtx, cancel := context.WithCancel(parent)
+		f = &flight{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		g.calls[key] = f
+		go g.run(callCtx, key, f, fn)
+	}
+	f.waiters++
+	g.mutex.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, ContextError(ctx.Err())
+	}
+}
+
+// leave records that a caller has given up on the call, cancelling it if
+// that was the last caller waiting for it.
+func (g *flightGroup) leave(key interface{}, f *flight) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	f.cancel()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key interface{}, f *flight, fn func(context.Context) (interface{}, error)) {
+	f.value, f.err = fn(ctx)
+	f.cancel()
+
+	g.mutex.Lock()
+	if g.calls[key] == f {
+		delete(g.calls, key)
+	}
+	g.mutex.Unlock()
+
+	close(f.done)
+}
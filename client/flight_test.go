@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+func TestFlightGroupSharesCall(t *testing.T) {
+	var (
+		g       flightGroup
+		mutex   sync.Mutex
+		running int
+		maxRun  int
+	)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	fn := func(context.Context) (interface{}, error) {
+		mutex.Lock()
+		running++
+		if running > maxRun {
+			maxRun = running
+		}
+		mutex.Unlock()
+
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return "value", nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make(chan interface{}, callers)
+	call := func() {
+		defer wg.Done()
+		value, err := g.do(context.Background(), "key", fn)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		results <- value
+	}
+
+	wg.Add(1)
+	go call()
+	<-started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		go call()
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for value := range results {
+		if value != "value" {
+			t.Errorf("got %v, want %q", value, "value")
+		}
+	}
+	if maxRun != 1 {
+		t.Errorf("calls for the same key ran concurrently: %d", maxRun)
+	}
+}
+
+func TestFlightGroupCallerCancelled(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	fn := func(context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		close(finished)
+		return "value", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		firstErr <- err
+	}()
+	<-started
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	second := make(chan result, 1)
+	go func() {
+		value, err := g.do(context.Background(), "key", func(context.Context) (interface{}, error) {
+			return "value", nil
+		})
+		second <- result{value: value, err: err}
+	}()
+
+	waitForWaiters(t, &g, "key", 2)
+
+	// Cancelling the caller that started the call must only fail that
+	// caller.
+	cancel()
+	if err := <-firstErr; errors.Cause(err) != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-finished:
+		t.Fatal("call finished before it was released")
+	default:
+	}
+
+	close(release)
+	r := <-second
+	if r.err != nil {
+		t.Fatalf("unexpected error: %v", r.err)
+	}
+	if r.value != "value" {
+		t.Fatalf("got %v, want %q", r.value, "value")
+	}
+	<-finished
+}
+
+func TestFlightGroupDeadlineExceeded(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := g.do(ctx, "key", func(context.Context) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	if !errors.IsTimeout(err) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+}
+
+func TestFlightGroupAllCallersGone(t *testing.T) {
+	var g flightGroup
+	abandoned := make(chan error, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		abandoned <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	const callers = 3
+	var wg sync.WaitGroup
+	cancels := make([]context.CancelFunc, callers)
+	for i := range cancels {
+		var ctx context.Context
+		ctx, cancels[i] = context.WithCancel(context.Background())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = g.do(ctx, "key", fn)
+		}()
+	}
+	waitForWaiters(t, &g, "key", callers)
+
+	// The call carries on until the last caller gives up.
+	for _, cancel := range cancels[1:] {
+		cancel()
+	}
+	select {
+	case <-abandoned:
+		t.Fatal("call abandoned while a caller was still waiting")
+	case <-time.After(10 * time.Millisecond):
+	}
+	cancels[0]()
+	wg.Wait()
+	if err := <-abandoned; err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFlightGroupRunsOnGroupContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := flightGroup{
+		ctx: ctx,
+	}
+	cancel()
+	_, err := g.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+// waitForWaiters waits until n callers are waiting on the call for key.
+func waitForWaiters(t *testing.T, g *flightGroup, key interface{}, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		g.mutex.Lock()
+		var waiters int
+		if f, ok := g.calls[key]; ok {
+			waiters = f.waiters
+		}
+		g.mutex.Unlock()
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d waiters, want %d", waiters, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/cmd/modelcmd"
+	"github.com/juju/juju/core/model"
+	"github.com/juju/juju/jujuclient"
+)
+
+const (
+	defaultControllerName = "controller"
+
+	envControllerName      = "JUJU_CONTROLLER"
+	envControllerUUID      = "JUJU_CONTROLLER_UUID"
+	envControllerAddresses = "JUJU_CONTROLLER_ADDRESSES"
+	envCACert              = "JUJU_CA_CERT"
+	envUsername            = "JUJU_USERNAME"
+	envPassword            = "JUJU_PASSWORD"
+	envModels              = "JUJU_MODELS"
+	envModel               = "JUJU_MODEL"
+)
+
+// MemoryStoreConfig holds the details used to seed an in-memory client
+// store with a single controller.
+type MemoryStoreConfig struct {
+	// ControllerName is the name the controller is stored under. It
+	// defaults to "controller".
+	ControllerName string
+
+	// ControllerUUID is the UUID of the controller.
+	ControllerUUID string
+
+	// APIEndpoints holds the controller's API addresses, in host:port form.
+	APIEndpoints []string
+
+	// CACert is the controller's CA certificate, in PEM form.
+	CACert string
+
+	// Account holds the user and, optionally, the password or macaroons
+	// used to log in.
+	Account jujuclient.AccountDetails
+
+	// Models maps model names to their details. Unqualified names are
+	// qualified with the account user. An empty model type defaults to
+	// "iaas".
+	Models map[string]jujuclient.ModelDetails
+
+	// CurrentModel is the model used when no model is selected, if any.
+	CurrentModel string
+}
+
+// NewMemoryStore returns a client store that is held entirely in memory,
+// seeded from the given config. Nothing written to the store, including
+// cookies, is persisted to disk.
+func NewMemoryStore(config MemoryStoreConfig) (jujuclient.ClientStore, error) {
+	controllerName := config.ControllerName
+	if controllerName == "" {
+		controllerName = defaultControllerName
+	}
+
+	store := modelcmd.QualifyingClientStore{
+		ClientStore: jujuclient.NewMemStore(),
+	}
+	if err := store.AddController(controllerName, jujuclient.ControllerDetails{
+		ControllerUUID: config.ControllerUUID,
+		APIEndpoints:   config.APIEndpoints,
+		CACert:         config.CACert,
+	}); err != nil {
+		return nil, errors.Annotatef(err, "adding controller %q", controllerName)
+	}
+	if err := store.SetCurrentController(controllerName); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := store.UpdateAccount(controllerName, config.Account); err != nil {
+		return nil, errors.Annotatef(err, "adding account for controller %q", controllerName)
+	}
+
+	for modelName, details := range config.Models {
+		if details.ModelType == "" {
+			details.ModelType = model.IAAS
+		}
+		if err := store.UpdateModel(controllerName, modelName, details); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if config.CurrentModel != "" {
+		if err := store.SetCurrentModel(controllerName, config.CurrentModel); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return store, nil
+}
+
+// NewMemoryStoreFromEnv returns an in-memory client store seeded from the
+// following environment variables:
+//
+//	JUJU_CONTROLLER           controller name (optional)
+//	JUJU_CONTROLLER_UUID      controller UUID
+//	JUJU_CONTROLLER_ADDRESSES comma separated API addresses
+//	JUJU_CA_CERT              CA certificate, or a path to a file holding it
+//	JUJU_USERNAME             user to log in as
+//	JUJU_PASSWORD             password for the user (optional)
+//	JUJU_MODELS               comma separated name=uuid[:type] entries (optional)
+//	JUJU_MODEL                current model name (optional)
+func NewMemoryStoreFromEnv() (jujuclient.ClientStore, error) {
+	config := MemoryStoreConfig{
+		ControllerName: os.Getenv(envControllerName),
+		ControllerUUID: os.Getenv(envControllerUUID),
+		APIEndpoints:   splitList(os.Getenv(envControllerAddresses)),
+		Account: jujuclient.AccountDetails{
+			User:     os.Getenv(envUsername),
+			Password: os.Getenv(envPassword),
+		},
+		CurrentModel: os.Getenv(envModel),
+	}
+	if len(config.APIEndpoints) == 0 {
+		return nil, errors.NotValidf("empty %s", envControllerAddresses)
+	}
+
+	caCert, err := readCACert(os.Getenv(envCACert))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config.CACert = caCert
+
+	models := splitList(os.Getenv(envModels))
+	if len(models) > 0 {
+		config.Models = make(map[string]jujuclient.ModelDetails, len(models))
+	}
+	for _, entry := range models {
+		modelName, details, err := parseModelEntry(entry)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		config.Models[modelName] = details
+	}
+
+	return NewMemoryStore(config)
+}
+
+// parseModelEntry parses a JUJU_MODELS entry of the form name=uuid[:type].
+// The model type defaults to "iaas" if it's omitted.
+func parseModelEntry(entry string) (string, jujuclient.ModelDetails, error) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", jujuclient.ModelDetails{}, errors.NotValidf("%s entry %q, expected name=uuid[:type]", envModels, entry)
+	}
+	details := jujuclient.ModelDetails{
+		ModelUUID: parts[1],
+	}
+	if i := strings.Index(parts[1], ":"); i >= 0 {
+		details.ModelUUID = parts[1][:i]
+		details.ModelType = model.ModelType(parts[1][i+1:])
+		switch details.ModelType {
+		case model.IAAS, model.CAAS:
+		default:
+			return "", jujuclient.ModelDetails{}, errors.NotValidf("%s entry %q model type %q", envModels, entry, details.ModelType)
+		}
+	}
+	if details.ModelUUID == "" {
+		return "", jujuclient.ModelDetails{}, errors.NotValidf("%s entry %q, expected name=uuid[:type]", envModels, entry)
+	}
+	return parts[0], details, nil
+}
+
+// readCACert returns the value as is if it holds a PEM certificate,
+// otherwise it's treated as the path of a file holding one.
+func readCACert(value string) (string, error) {
+	if value == "" || strings.Contains(value, "-----BEGIN") {
+		return value, nil
+	}
+	data, err := ioutil.ReadFile(value)
+	if err != nil {
+		return "", errors.Annotatef(err, "reading CA certificate")
+	}
+	return string(data), nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
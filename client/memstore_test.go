@@ -0,0 +1,67 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/core/model"
+	"github.com/juju/juju/jujuclient"
+)
+
+func TestParseModelEntry(t *testing.T) {
+	tests := []struct {
+		entry     string
+		modelName string
+		details   jujuclient.ModelDetails
+		err       bool
+	}{{
+		entry:     "default=deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		modelName: "default",
+		details: jujuclient.ModelDetails{
+			ModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		},
+	}, {
+		entry:     "k8s=deadbeef-0bad-400d-8000-4b1d0d06f00d:caas",
+		modelName: "k8s",
+		details: jujuclient.ModelDetails{
+			ModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+			ModelType: model.CAAS,
+		},
+	}, {
+		entry:     "admin/default=deadbeef-0bad-400d-8000-4b1d0d06f00d:iaas",
+		modelName: "admin/default",
+		details: jujuclient.ModelDetails{
+			ModelUUID: "deadbeef-0bad-400d-8000-4b1d0d06f00d",
+			ModelType: model.IAAS,
+		},
+	}, {
+		entry: "default=deadbeef-0bad-400d-8000-4b1d0d06f00d:vm",
+		err:   true,
+	}, {
+		entry: "default=:caas",
+		err:   true,
+	}, {
+		entry: "default",
+		err:   true,
+	}, {
+		entry: "=deadbeef-0bad-400d-8000-4b1d0d06f00d",
+		err:   true,
+	}}
+	for _, test := range tests {
+		modelName, details, err := parseModelEntry(test.entry)
+		if test.err {
+			if !errors.IsNotValid(err) {
+				t.Errorf("%q: got %v, want a NotValid error", test.entry, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.entry, err)
+			continue
+		}
+		if modelName != test.modelName || details != test.details {
+			t.Errorf("%q: got %q %+v, want %q %+v", test.entry, modelName, details, test.modelName, test.details)
+		}
+	}
+}
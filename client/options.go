@@ -0,0 +1,88 @@
+package client
+
+import (
+	"time"
+
+	"github.com/juju/juju/jujuclient"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	store          jujuclient.ClientStore
+	controllerName string
+	modelName      string
+	credentials    CredentialProvider
+	redirects      *RedirectPolicy
+	retry          *RetryPolicy
+
+	cookieFlushInterval time.Duration
+}
+
+// WithClientStore sets the store used to look up controllers, accounts and
+// models. If not set, the user's juju file store is used.
+func WithClientStore(store jujuclient.ClientStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithController selects the controller to connect to, instead of the
+// store's current controller.
+func WithController(controllerName string) Option {
+	return func(o *options) {
+		o.controllerName = controllerName
+	}
+}
+
+// WithModel selects the default model used when no model name is passed
+// to NewModelAPIRoot, instead of the controller's current model.
+func WithModel(modelName string) Option {
+	return func(o *options) {
+		o.modelName = modelName
+	}
+}
+
+// WithCredentials sets the provider used to obtain login credentials. If
+// not set, only the account details in the store are used, and the client
+// never prompts for input.
+func WithCredentials(provider CredentialProvider) Option {
+	return func(o *options) {
+		o.credentials = provider
+	}
+}
+
+// WithRedirects enables following model migration redirects, using the
+// given policy to decide whether the target controller can be trusted. If
+// not set, a redirect is returned as an error describing how to log in to
+// the target controller.
+func WithRedirects(policy RedirectPolicy) Option {
+	return func(o *options) {
+		o.redirects = &policy
+	}
+}
+
+// WithRetryPolicy retries failed connection attempts according to the
+// given policy. If not set, a single attempt is made.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = &policy
+	}
+}
+
+// WithCookieFlushInterval periodically saves the cookies obtained while
+// logging in, rather than only when the client is closed.
+func WithCookieFlushInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.cookieFlushInterval = interval
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
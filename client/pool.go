@@ -0,0 +1,288 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+)
+
+const (
+	// defaultIdleTimeout is how long a pooled connection may go unused
+	// before it is closed and evicted from the pool.
+	defaultIdleTimeout = 5 * time.Minute
+)
+
+// connKey identifies a pooled connection. An empty modelUUID refers to a
+// controller-only connection.
+type connKey struct {
+	controllerName string
+	modelUUID      string
+}
+
+// String returns the controller name, followed by the model UUID for a
+// model connection.
+func (k connKey) String() string {
+	if k.modelUUID == "" {
+		return fmt.Sprintf("%q", k.controllerName)
+	}
+	return fmt.Sprintf("%q model %s", k.controllerName, k.modelUUID)
+}
+
+// pooledConn holds a live connection along with the number of references
+// to it that have been handed out and not yet closed, and the last time it
+// was handed out or released.
+type pooledConn struct {
+	conn     api.Connection
+	refs     int
+	lastUsed time.Time
+	// evicted is set once the connection has been removed from the pool
+	// while still referenced, so that it's closed on its last release.
+	evicted bool
+}
+
+// connPool keeps live API connections keyed by controller and model, so
+// that repeated calls for the same model reuse a single websocket.
+type connPool struct {
+	clock       clock.Clock
+	idleTimeout time.Duration
+
+	// dials ensures only one connection is dialed per key at a time. The
+	// dials run on a context that's cancelled when the pool is closed.
+	dials  flightGroup
+	cancel context.CancelFunc
+
+	mutex  sync.Mutex
+	conns  map[connKey]*pooledConn
+	closed bool
+	done   chan struct{}
+}
+
+// newConnPool returns a pool that evicts connections that have been idle
+// for longer than idleTimeout. The returned pool must be closed after use.
+func newConnPool(clock clock.Clock, idleTimeout time.Duration) *connPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &connPool{
+		clock:       clock,
+		idleTimeout: idleTimeout,
+		dials: flightGroup{
+			ctx: ctx,
+		},
+		cancel: cancel,
+		conns:  make(map[connKey]*pooledConn),
+		done:   make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// get returns a healthy connection for the given key. If there is no cached
+// connection, or the cached one is broken, a new one is opened using dial.
+// Concurrent callers for the same key share a single dial, which carries on
+// while any of them is still waiting for it. The context passed to dial is
+// cancelled once they have all given up, or the pool is closed. Each caller
+// gets its own reference to the connection.
+func (p *connPool) get(ctx context.Context, key connKey, dial func(context.Context) (api.Connection, error)) (api.Connection, error) {
+	p.mutex.Lock()
+	if pooled, ok := p.conns[key]; ok && !p.closed && !isBroken(pooled.conn) {
+		shared := p.acquire(pooled)
+		p.mutex.Unlock()
+		return shared, nil
+	}
+	p.mutex.Unlock()
+
+	pooled, err := p.dials.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return p.getOrDial(ctx, key, dial)
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil, errors.New("connection pool closed")
+	}
+	return p.acquire(pooled.(*pooledConn)), nil
+}
+
+func (p *connPool) getOrDial(ctx context.Context, key connKey, dial func(context.Context) (api.Connection, error)) (*pooledConn, error) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return nil, errors.New("connection pool closed")
+	}
+	if pooled, ok := p.conns[key]; ok {
+		if !isBroken(pooled.conn) {
+			p.mutex.Unlock()
+			return pooled, nil
+		}
+		// The connection is broken, so drop it and redial.
+		delete(p.conns, key)
+		_ = pooled.conn.Close()
+	}
+	p.mutex.Unlock()
+
+	conn, err := dial(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		_ = conn.Close()
+		return nil, errors.New("connection pool closed")
+	}
+	// The connection counts as used now, so that it isn't evicted before
+	// the callers waiting on the dial have acquired it.
+	pooled := &pooledConn{
+		conn:     conn,
+		lastUsed: p.clock.Now(),
+	}
+	p.conns[key] = pooled
+	return pooled, nil
+}
+
+// acquire hands out a reference to the pooled connection. It must be called
+// with the mutex held.
+func (p *connPool) acquire(pooled *pooledConn) *sharedConnection {
+	pooled.refs++
+	pooled.lastUsed = p.clock.Now()
+	return &sharedConnection{
+		Connection: pooled.conn,
+		pool:       p,
+		pooled:     pooled,
+	}
+}
+
+// release returns a reference to the pooled connection. The connection
+// stays open, and is evicted once it has been idle for long enough without
+// any references, unless it has already been evicted, in which case it's
+// closed once the last reference is released.
+func (p *connPool) release(pooled *pooledConn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pooled.refs--
+	pooled.lastUsed = p.clock.Now()
+	if pooled.evicted && pooled.refs == 0 {
+		_ = pooled.conn.Close()
+	}
+}
+
+// evict removes the connection for the given key from the pool, so that
+// it's no longer handed out. It's closed straight away if it isn't
+// referenced, otherwise once the last reference is released.
+func (p *connPool) evict(key connKey) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	pooled, ok := p.conns[key]
+	if !ok {
+		return
+	}
+	delete(p.conns, key)
+	if pooled.refs > 0 {
+		pooled.evicted = true
+		return
+	}
+	_ = pooled.conn.Close()
+}
+
+// Close closes every pooled connection and stops the eviction loop,
+// returning the error from each connection that failed to close.
+func (p *connPool) Close() []error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.cancel()
+
+	var errs []error
+	for key, pooled := range p.conns {
+		if err := pooled.conn.Close(); err != nil {
+			errs = append(errs, errors.Annotatef(err, "closing connection to %s", key))
+		}
+		delete(p.conns, key)
+	}
+	return errs
+}
+
+func (p *connPool) loop() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.clock.After(p.idleTimeout / 2):
+			p.evictIdle()
+		}
+	}
+}
+
+// evictIdle closes and removes any connections that are either broken, or
+// have had no references for longer than the idle timeout. Connections that
+// are still referenced are never closed, however long they're held.
+func (p *connPool) evictIdle() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.clock.Now()
+	for key, pooled := range p.conns {
+		if isBroken(pooled.conn) {
+			// Callers holding a broken connection get errors from it
+			// regardless, so it's dropped straight away.
+			_ = pooled.conn.Close()
+			delete(p.conns, key)
+			continue
+		}
+		if pooled.refs > 0 || now.Sub(pooled.lastUsed) < p.idleTimeout {
+			continue
+		}
+		_ = pooled.conn.Close()
+		delete(p.conns, key)
+	}
+}
+
+// isBroken checks the connection's broken channel without blocking. Unlike
+// api.Connection.IsBroken, it doesn't make a ping round trip.
+func isBroken(conn api.Connection) bool {
+	select {
+	case <-conn.Broken():
+		return true
+	default:
+		return false
+	}
+}
+
+// sharedConnection is a reference to a connection handed out by the pool.
+// The underlying connection is owned by the pool, so closing a shared
+// connection only releases the reference; the connection itself is closed
+// once it's idle, or by Client.Close.
+type sharedConnection struct {
+	api.Connection
+
+	pool      *connPool
+	pooled    *pooledConn
+	closeOnce sync.Once
+}
+
+// Close is part of the api.Connection interface. Closing a shared
+// connection more than once releases it only once.
+func (c *sharedConnection) Close() error {
+	c.closeOnce.Do(func() {
+		c.pool.release(c.pooled)
+	})
+	return nil
+}
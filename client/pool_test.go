@@ -0,0 +1,269 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juju/clock/testclock"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+)
+
+// fakeConn is an api.Connection that only supports the methods used by the
+// pool.
+type fakeConn struct {
+	api.Connection
+
+	broken   chan struct{}
+	closeErr error
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		broken: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) Broken() <-chan struct{} {
+	return c.broken
+}
+
+func (c *fakeConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return c.closeErr
+}
+
+func (c *fakeConn) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+var testKey = connKey{controllerName: "ctrl", modelUUID: "uuid"}
+
+func TestPoolConcurrentGetDialsOnce(t *testing.T) {
+	p := newConnPool(testclock.NewClock(time.Now()), time.Minute)
+	defer func() { _ = p.Close() }()
+
+	var dials int32
+	conn := newFakeConn()
+	dial := func(context.Context) (api.Connection, error) {
+		atomic.AddInt32(&dials, 1)
+		time.Sleep(10 * time.Millisecond)
+		return conn, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	shared := make(chan api.Connection, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.get(context.Background(), testKey, dial)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			shared <- c
+		}()
+	}
+	wg.Wait()
+	close(shared)
+
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Fatalf("dialed %d times, want 1", n)
+	}
+	var count int
+	for c := range shared {
+		if c.(*sharedConnection).Connection != conn {
+			t.Errorf("got a different connection")
+		}
+		_ = c.Close()
+		count++
+	}
+	if count != callers {
+		t.Fatalf("got %d connections, want %d", count, callers)
+	}
+	p.mutex.Lock()
+	refs := p.conns[testKey].refs
+	p.mutex.Unlock()
+	if refs != 0 {
+		t.Fatalf("got %d references after closing, want 0", refs)
+	}
+}
+
+func TestPoolGetCancelled(t *testing.T) {
+	p := newConnPool(testclock.NewClock(time.Now()), time.Minute)
+	defer func() { _ = p.Close() }()
+
+	var dials int32
+	conn := newFakeConn()
+	abandoned := make(chan struct{})
+	dial := func(ctx context.Context) (api.Connection, error) {
+		if atomic.AddInt32(&dials, 1) > 1 {
+			return conn, nil
+		}
+		<-ctx.Done()
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.get(ctx, testKey, dial)
+		errs <- err
+	}()
+	cancel()
+	if err := <-errs; errors.Cause(err) != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	// Nobody else is waiting on the dial, so it's abandoned, and the
+	// next caller dials afresh.
+	<-abandoned
+	c, err := p.get(context.Background(), testKey, dial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	if c.(*sharedConnection).Connection != conn {
+		t.Fatal("got a different connection")
+	}
+	if n := atomic.LoadInt32(&dials); n != 2 {
+		t.Fatalf("dialed %d times, want 2", n)
+	}
+}
+
+func TestPoolEvictsOnlyUnreferenced(t *testing.T) {
+	clk := testclock.NewClock(time.Now())
+	p := newConnPool(clk, time.Minute)
+	defer func() { _ = p.Close() }()
+
+	conn := newFakeConn()
+	c, err := p.get(context.Background(), testKey, func(context.Context) (api.Connection, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clk.Advance(2 * time.Minute)
+	p.evictIdle()
+	if conn.isClosed() {
+		t.Fatal("referenced connection was evicted")
+	}
+
+	// Closing twice releases the reference once.
+	_ = c.Close()
+	_ = c.Close()
+	p.mutex.Lock()
+	refs := p.conns[testKey].refs
+	p.mutex.Unlock()
+	if refs != 0 {
+		t.Fatalf("got %d references, want 0", refs)
+	}
+
+	p.evictIdle()
+	if conn.isClosed() {
+		t.Fatal("connection was evicted before it was idle")
+	}
+	clk.Advance(2 * time.Minute)
+	p.evictIdle()
+	if !conn.isClosed() {
+		t.Fatal("idle connection wasn't evicted")
+	}
+}
+
+func TestPoolCloseDuringDial(t *testing.T) {
+	p := newConnPool(testclock.NewClock(time.Now()), time.Minute)
+
+	conn := newFakeConn()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.get(context.Background(), testKey, func(context.Context) (api.Connection, error) {
+			close(started)
+			<-release
+			return conn, nil
+		})
+		errs <- err
+	}()
+	<-started
+	if errs := p.Close(); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	close(release)
+	if err := <-errs; err == nil {
+		t.Fatal("expected an error from a closed pool")
+	}
+	if !conn.isClosed() {
+		t.Fatal("connection dialed after close wasn't closed")
+	}
+}
+
+func TestPoolCloseReturnsEveryError(t *testing.T) {
+	p := newConnPool(testclock.NewClock(time.Now()), time.Minute)
+
+	for _, key := range []connKey{
+		{controllerName: "ctrl"},
+		{controllerName: "ctrl", modelUUID: "uuid-1"},
+		{controllerName: "ctrl", modelUUID: "uuid-2"},
+	} {
+		conn := newFakeConn()
+		if key.modelUUID != "" {
+			conn.closeErr = errors.New("boom")
+		}
+		c, err := p.get(context.Background(), key, func(context.Context) (api.Connection, error) {
+			return conn, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = c.Close()
+	}
+
+	if errs := p.Close(); len(errs) != 2 {
+		t.Fatalf("got errors %v, want 2", errs)
+	}
+}
+
+func TestPoolEvictReferenced(t *testing.T) {
+	p := newConnPool(testclock.NewClock(time.Now()), time.Minute)
+	defer func() { _ = p.Close() }()
+
+	conn := newFakeConn()
+	c, err := p.get(context.Background(), testKey, func(context.Context) (api.Connection, error) {
+		return conn, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.evict(testKey)
+	if conn.isClosed() {
+		t.Fatal("referenced connection was closed on eviction")
+	}
+	p.mutex.Lock()
+	_, ok := p.conns[testKey]
+	p.mutex.Unlock()
+	if ok {
+		t.Fatal("evicted connection is still pooled")
+	}
+
+	_ = c.Close()
+	if !conn.isClosed() {
+		t.Fatal("evicted connection wasn't closed on its last release")
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+
+	"github.com/juju/collections/set"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+	"github.com/juju/juju/core/network"
+	"github.com/juju/juju/jujuclient"
+	"github.com/juju/juju/pki"
+)
+
+// TrustPolicy decides whether a controller that a model has been migrated
+// to can be trusted, given its CA certificate fingerprint. A non-nil error
+// aborts the redirect.
+type TrustPolicy func(controllerUUID, fingerprint string) error
+
+// TrustFingerprints returns a trust policy that only accepts controllers
+// whose CA certificate has one of the given fingerprints.
+func TrustFingerprints(fingerprints ...string) TrustPolicy {
+	trusted := set.NewStrings(fingerprints...)
+	return func(controllerUUID, fingerprint string) error {
+		if !trusted.Contains(fingerprint) {
+			return errors.Unauthorizedf("controller %q CA fingerprint %s", controllerUUID, fingerprint)
+		}
+		return nil
+	}
+}
+
+// Migration describes a redirect that has been followed.
+type Migration struct {
+	// ModelName is the name of the migrated model.
+	ModelName string
+	// SourceController is the name of the controller the model was on.
+	SourceController string
+	// TargetController is the name the target controller is registered
+	// under in the client store.
+	TargetController string
+	// TargetControllerUUID is the UUID of the target controller.
+	TargetControllerUUID string
+	// APIEndpoints holds the target controller's API addresses.
+	APIEndpoints []string
+	// Fingerprint is the target controller's CA certificate fingerprint.
+	Fingerprint string
+}
+
+// RedirectPolicy controls how model migration redirects are followed.
+type RedirectPolicy struct {
+	// Trust decides whether the target controller can be trusted. It is
+	// required; controllers already known to the store are always trusted.
+	Trust TrustPolicy
+
+	// OnMigrate, if set, is called each time a redirect is followed
+	// successfully.
+	OnMigrate func(Migration)
+}
+
+// followRedirect registers the controller a model has been migrated to,
+// along with the model and the user's account, and opens a connection to
+// the model on that controller.
+func (c *Client) followRedirect(ctx context.Context, modelName string, redirErr *api.RedirectError) (api.Connection, error) {
+	allEndpoints := network.CollapseToHostPorts(redirErr.Servers).Strings()
+	fingerprint, _, err := pki.Fingerprint([]byte(redirErr.CACert))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	controllerUUID := redirErr.ControllerTag.Id()
+
+	targetName, err := c.registerMigration(controllerUUID, modelName, allEndpoints, fingerprint, redirErr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	conn, err := c.dialAPIRoot(ctx, targetName, modelName)
+	if err != nil {
+		return nil, errors.Annotatef(err, "connecting to migrated model %q", modelName)
+	}
+
+	if c.redirects.OnMigrate != nil {
+		c.redirects.OnMigrate(Migration{
+			ModelName:            modelName,
+			SourceController:     c.controllerName,
+			TargetController:     targetName,
+			TargetControllerUUID: controllerUUID,
+			APIEndpoints:         allEndpoints,
+			Fingerprint:          fingerprint,
+		})
+	}
+	return conn, nil
+}
+
+// registerMigration ensures the target controller and the model are known
+// to the store, returning the name of the target controller.
+func (c *Client) registerMigration(
+	controllerUUID, modelName string,
+	allEndpoints []string, fingerprint string,
+	redirErr *api.RedirectError,
+) (string, error) {
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+
+	_, targetName, err := c.store.ControllerByAPIEndpoints(allEndpoints...)
+	if errors.IsNotFound(err) {
+		if c.redirects.Trust == nil {
+			return "", errors.New("following redirects requires a trust policy")
+		}
+		if err := c.redirects.Trust(controllerUUID, fingerprint); err != nil {
+			return "", errors.Annotatef(err, "model %q migrated to untrusted controller", modelName)
+		}
+		if targetName, err = c.registerController(controllerUUID, redirErr.ControllerAlias, allEndpoints, redirErr.CACert); err != nil {
+			return "", errors.Trace(err)
+		}
+	} else if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if err := c.copyModel(targetName, modelName); err != nil {
+		return "", errors.Trace(err)
+	}
+	return targetName, nil
+}
+
+// registerController adds the target controller to the store, along with
+// the user's account from the source controller, returning the name it was
+// registered under.
+func (c *Client) registerController(controllerUUID, alias string, endpoints []string, caCert string) (string, error) {
+	name := alias
+	if name == "" {
+		name = controllerUUID
+	}
+	if err := c.store.AddController(name, jujuclient.ControllerDetails{
+		ControllerUUID: controllerUUID,
+		APIEndpoints:   endpoints,
+		CACert:         caCert,
+	}); err != nil {
+		return "", errors.Annotatef(err, "registering controller %q", name)
+	}
+
+	// Users move with the model, but macaroons are specific to the
+	// controller that issued them, so only the user and password are kept.
+	accountDetails, err := c.store.AccountDetails(c.controllerName)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := c.store.UpdateAccount(name, jujuclient.AccountDetails{
+		User:     accountDetails.User,
+		Password: accountDetails.Password,
+	}); err != nil {
+		return "", errors.Annotatef(err, "registering account on controller %q", name)
+	}
+	return name, nil
+}
+
+// copyModel records the model against the target controller, so that it
+// can be looked up when dialing.
+func (c *Client) copyModel(targetName, modelName string) error {
+	details, err := c.store.ModelByName(c.controllerName, modelName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := c.store.UpdateModel(targetName, modelName, *details); err != nil {
+		return errors.Annotatef(err, "registering model %q on controller %q", modelName, targetName)
+	}
+	return nil
+}
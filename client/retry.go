@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/errors"
+	"github.com/juju/retry"
+
+	"github.com/juju/juju/api"
+	"github.com/juju/juju/apiserver/params"
+	"github.com/juju/juju/rpc"
+)
+
+// Attempt describes a single attempt to connect to a controller.
+type Attempt struct {
+	// Number is the attempt number, starting at 1.
+	Number int
+	// Err is the error the attempt failed with, or nil if it succeeded.
+	Err error
+}
+
+// RetryPolicy controls how connecting to a controller is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	MaxAttempts int
+
+	// Delay is the delay before the first retry.
+	Delay time.Duration
+
+	// MaxDelay caps the delay between retries. If zero, there is no cap.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor the delay grows by after each retry. If
+	// zero, it defaults to 2.
+	Multiplier float64
+
+	// Jitter randomises each delay to between half and all of its value,
+	// so that many clients don't retry in lockstep.
+	Jitter bool
+
+	// Retryable reports whether an error is worth retrying. If nil,
+	// IsRetryableError is used.
+	Retryable func(error) bool
+
+	// AddressTimeout, if set, bounds the time spent dialing each of the
+	// controller's addresses. Addresses are then tried one at a time,
+	// failing over to the next one on a retryable error.
+	AddressTimeout time.Duration
+
+	// OnAttempt, if set, is called after each attempt.
+	OnAttempt func(Attempt)
+
+	// Clock is used to wait between retries. If nil, the wall clock is
+	// used.
+	Clock clock.Clock
+}
+
+// DefaultRetryPolicy returns a retry policy suitable for riding out
+// controller failover and upgrades.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		Delay:          time.Second,
+		MaxDelay:       30 * time.Second,
+		Multiplier:     2,
+		Jitter:         true,
+		AddressTimeout: 10 * time.Second,
+	}
+}
+
+// IsRetryableError reports whether the error is likely to be transient,
+// such as a network failure, a dropped connection or a controller that is
+// upgrading.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if params.IsCodeTryAgain(err) || params.IsCodeUpgradeInProgress(err) {
+		return true
+	}
+	switch cause := errors.Cause(err); cause {
+	case io.EOF, io.ErrUnexpectedEOF, rpc.ErrShutdown:
+		return true
+	default:
+		_, ok := cause.(net.Error)
+		return ok
+	}
+}
+
+// call calls fn until it succeeds, returns an error that isn't retryable,
+// runs out of attempts or the context is done.
+func (p RetryPolicy) call(ctx context.Context, fn func() (api.Connection, error)) (api.Connection, error) {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryableError
+	}
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.WallClock
+	}
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := p.Delay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	var (
+		conn    api.Connection
+		attempt int
+	)
+	err := retry.Call(retry.CallArgs{
+		Func: func() error {
+			attempt++
+			var err error
+			conn, err = fn()
+			if p.OnAttempt != nil {
+				p.OnAttempt(Attempt{
+					Number: attempt,
+					Err:    err,
+				})
+			}
+			return err
+		},
+		IsFatalError: func(err error) bool {
+			return ctx.Err() != nil || !retryable(err)
+		},
+		Attempts:    attempts,
+		Delay:       delay,
+		MaxDelay:    p.MaxDelay,
+		BackoffFunc: p.backoff(delay),
+		Clock:       clk,
+		Stop:        ctx.Done(),
+	})
+	if err == nil {
+		return conn, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ContextError(ctx.Err())
+	}
+	return nil, errors.Trace(retry.LastError(err))
+}
+
+// backoff returns a function computing the delay before each retry from
+// the initial delay, rather than the previous (possibly jittered) one.
+func (p RetryPolicy) backoff(initial time.Duration) func(time.Duration, int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	return func(_ time.Duration, attempt int) time.Duration {
+		delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+		if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+			delay = float64(p.MaxDelay)
+		}
+		if p.Jitter {
+			delay = delay/2 + rand.Float64()*delay/2
+		}
+		return time.Duration(delay)
+	}
+}
+
+// failover wraps the given open function so that, when an address timeout
+// is set, each of the controller's addresses is dialed in turn.
+func (p RetryPolicy) failover(open api.OpenFunc) api.OpenFunc {
+	if p.AddressTimeout <= 0 {
+		return open
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryableError
+	}
+	return func(info *api.Info, opts api.DialOpts) (api.Connection, error) {
+		opts.DialTimeout = p.AddressTimeout
+
+		var lastErr error
+		for _, addr := range info.Addrs {
+			addrInfo := *info
+			addrInfo.Addrs = []string{addr}
+			conn, err := open(&addrInfo, opts)
+			if err == nil {
+				return conn, nil
+			}
+			if !retryable(err) {
+				return nil, errors.Trace(err)
+			}
+			lastErr = errors.Annotatef(err, "dialing %s", addr)
+		}
+		if lastErr == nil {
+			return open(info, opts)
+		}
+		return nil, lastErr
+	}
+}
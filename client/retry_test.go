@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/clock/testclock"
+	"github.com/juju/errors"
+
+	"github.com/juju/juju/api"
+)
+
+func TestRetryPolicyCancelledDuringBackoff(t *testing.T) {
+	clk := testclock.NewClock(time.Now())
+	var attempts int
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		Delay:       time.Minute,
+		Clock:       clk,
+		OnAttempt: func(Attempt) {
+			attempts++
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := policy.call(ctx, func() (api.Connection, error) {
+			return nil, io.EOF
+		})
+		errs <- err
+	}()
+
+	// Wait for the backoff to start before cancelling.
+	<-clk.Alarms()
+	cancel()
+	if err := <-errs; errors.Cause(err) != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if attempts != 1 {
+		t.Fatalf("got %d attempts, want 1", attempts)
+	}
+}
+
+func TestRetryPolicyConcurrentCalls(t *testing.T) {
+	clk := testclock.NewClock(time.Now())
+	var (
+		mutex    sync.Mutex
+		attempts int
+	)
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		Delay:       time.Second,
+		Jitter:      true,
+		Clock: &testclock.AutoAdvancingClock{
+			Clock:   clk,
+			Advance: clk.Advance,
+		},
+		OnAttempt: func(Attempt) {
+			mutex.Lock()
+			attempts++
+			mutex.Unlock()
+		},
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var failures int
+			conn, err := policy.call(context.Background(), func() (api.Connection, error) {
+				if failures < 2 {
+					failures++
+					return nil, io.EOF
+				}
+				return newFakeConn(), nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			_ = conn.Close()
+		}()
+	}
+	wg.Wait()
+
+	if attempts != 3*callers {
+		t.Fatalf("got %d attempts, want %d", attempts, 3*callers)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxDelay: 5 * time.Second,
+	}
+	backoff := policy.backoff(time.Second)
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if got := backoff(0, attempt+1); got != want {
+			t.Errorf("attempt %d: got %v, want %v", attempt+1, got, want)
+		}
+	}
+
+	policy.Jitter = true
+	backoff = policy.backoff(time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := 1; attempt <= 4; attempt++ {
+				got := backoff(0, attempt)
+				if got < 500*time.Millisecond || got > 5*time.Second {
+					t.Errorf("attempt %d: delay %v out of range", attempt, got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,13 +10,15 @@ import (
 	"github.com/SimonRichardson/juju-api-example/api"
 	"github.com/SimonRichardson/juju-api-example/client"
 	"github.com/juju/charm/v8"
+	"github.com/juju/juju/apiserver/params"
 )
 
 func main() {
-	client, err := client.NewClient()
+	client, err := client.NewClient(client.WithCredentials(client.InteractiveCredentials()))
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() { _ = client.Close() }()
 
 	applicationsAPI := api.NewApplicationsAPI(client)
 	if err := applicationsAPI.Deploy("default", "ubuntu", api.DeployArgs{
@@ -29,14 +32,30 @@ func main() {
 
 	statusAPI := api.NewStatusAPI(client)
 
-	ticker := time.NewTicker(time.Second)
-	for range ticker.C {
-		status, err := statusAPI.FullStatus(nil)
-		if err != nil {
-			log.Fatal(err)
-		}
-		dump("Status", status)
+	// Print only what changes while waiting for the application.
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	go func() {
+		_ = statusAPI.PollChanges(pollCtx, "default", time.Second, func(_ *params.FullStatus, changes []api.StatusChange) {
+			for _, change := range changes {
+				fmt.Println(change)
+			}
+		})
+	}()
+
+	if err := statusAPI.Wait(context.Background(), "default", api.WaitArgs{
+		Applications: []string{"ubuntu"},
+		Conditions:   []api.WaitCondition{api.UnitCount(1), api.WorkloadActive(), api.AgentIdle()},
+		Timeout:      10 * time.Minute,
+	}); err != nil {
+		log.Fatalf("%+v\n", err)
+	}
+	stopPolling()
+
+	status, err := statusAPI.FullStatus(nil)
+	if err != nil {
+		log.Fatal(err)
 	}
+	dump("Status", status)
 }
 
 func dump(name string, value interface{}) {
@@ -11,9 +11,13 @@ require (
 	github.com/juju/errors v0.0.0-20210818161939-5560c4c073ff
 	github.com/juju/idmclient/v2 v2.0.0-20210309081103-6b4a5212f851
 	github.com/juju/juju v0.0.0-20211201065255-8a154b7d629f
+	github.com/juju/loggo v0.0.0-20210728185423-eebad3a902c4
 	github.com/juju/names/v4 v4.0.0-20200929085019-be23e191fee0
+	github.com/juju/retry v0.0.0-20180821225755-9058e192b216
 	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 	gopkg.in/juju/environschema.v1 v1.0.1-0.20201027142642-c89a4490670a
+	gopkg.in/macaroon.v2 v2.1.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 replace github.com/hashicorp/raft => github.com/juju/raft v2.0.0-20200420012049-88ad3b3f0a54+incompatible